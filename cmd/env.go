@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// varEnvPrefix marks environment variables that define template variables,
+// e.g. RJG_VAR_user defines $user.
+const varEnvPrefix = "RJG_VAR_"
+
+// applyEnv fills in settings from the environment. Flags take precedence
+// over the environment, which takes precedence over defaults and
+// --vars-file: RJG_COUNT sets --count unless it is passed, and each
+// RJG_VAR_<NAME> defines the variable NAME unless --var defines it.
+func applyEnv(cmd *cobra.Command, args *Args) error {
+	if v, ok := os.LookupEnv("RJG_COUNT"); ok && !cmd.Flags().Changed("count") {
+		count, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("invalid RJG_COUNT %q: %w", v, err)
+		}
+		args.count = count
+	}
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		name, ok := strings.CutPrefix(key, varEnvPrefix)
+		if !ok || name == "" {
+			continue
+		}
+		if _, exists := args.variables[name]; !exists {
+			args.variables[name] = value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVariablePrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		flag     string // value of --var label=...
+		env      string // value of RJG_VAR_label
+		varsFile string // value of label in --vars-file
+		want     string
+	}{
+		{"flag over env", "1", "2", "", `{"v":1}`},
+		{"flag over vars file", "1", "", "3", `{"v":1}`},
+		{"env over vars file", "", "2", "3", `{"v":2}`},
+		{"env alone", "", "2", "", `{"v":2}`},
+		{"vars file alone", "", "", "3", `{"v":3}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			args := []string{"--no-file"}
+			var env []string
+			if tt.flag != "" {
+				args = append(args, "-v", "label="+tt.flag)
+			}
+			if tt.env != "" {
+				env = append(env, "RJG_VAR_label="+tt.env)
+			}
+			if tt.varsFile != "" {
+				if err := os.WriteFile(filepath.Join(dir, "vars.json"), []byte(`{"label":`+tt.varsFile+`}`), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, "--vars-file", "vars.json")
+			}
+			res := runCLI(t, dir, env, append(args, `{"v":"$label"}`)...)
+			if res.code != 0 {
+				t.Fatalf("exit code %d, stderr: %s", res.code, res.stderr)
+			}
+			if got := strings.TrimSpace(res.stdout); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  []string
+		want int
+	}{
+		{"flag over env", []string{"-c", "2"}, []string{"RJG_COUNT=4"}, 2},
+		{"env over default", nil, []string{"RJG_COUNT= 3 "}, 3},
+		{"default", nil, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append(append([]string{"--no-file"}, tt.args...), `{"a":1}`)
+			res := runCLI(t, t.TempDir(), tt.env, args...)
+			if res.code != 0 {
+				t.Fatalf("exit code %d, stderr: %s", res.code, res.stderr)
+			}
+			if got := len(lines(res.stdout)); got != tt.want {
+				t.Errorf("got %d records, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidEnvCount(t *testing.T) {
+	res := runCLI(t, t.TempDir(), []string{"RJG_COUNT=many"}, "--no-file", `{"a":1}`)
+	if res.code != 1 || !strings.Contains(res.stderr, `invalid RJG_COUNT "many"`) {
+		t.Errorf("exit code %d, stderr %q; want an invalid RJG_COUNT to be rejected", res.code, res.stderr)
+	}
+	// A --count flag makes RJG_COUNT irrelevant
+	res = runCLI(t, t.TempDir(), []string{"RJG_COUNT=many"}, "--no-file", "-c", "1", `{"a":1}`)
+	if res.code != 0 {
+		t.Errorf("exit code %d, stderr %q; want --count to override RJG_COUNT", res.code, res.stderr)
+	}
+}
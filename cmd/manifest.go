@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+)
+
+// Manifest records the parameters and results of a run so the output can be
+// audited and verified later.
+type Manifest struct {
+	Seed         *int64       `json:"seed,omitempty"`
+	Count        int          `json:"count"`
+	TemplateHash string       `json:"templateSha256"`
+	Outputs      []OutputFile `json:"outputs"`
+	Records      int          `json:"records"`
+	Checksum     string       `json:"sha256"`
+}
+
+// OutputFile is an output file of a run with the checksum of its bytes. A
+// run split with --split has one per shard.
+type OutputFile struct {
+	Path     string `json:"path"`
+	Checksum string `json:"sha256"`
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeManifest(path string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readManifest reads the manifest a run wrote to path.
+func readManifest(t *testing.T, path string) Manifest {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("decoding manifest: %v", err)
+	}
+	return m
+}
+
+// fileSha256 returns the checksum of the file at path.
+func fileSha256(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha256Hex(data)
+}
+
+func TestManifestChecksumCoversCompressedFile(t *testing.T) {
+	dir := t.TempDir()
+	res := runCLI(t, dir, nil, "-q", "-c", "5", "-s", "1", "--gzip", "--manifest", "manifest.json", `{"a":"$u8"}`)
+	if res.code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", res.code, res.stderr)
+	}
+	m := readManifest(t, filepath.Join(dir, "manifest.json"))
+	if want := fileSha256(t, filepath.Join(dir, "commands.jsonl.gz")); m.Checksum != want {
+		t.Errorf("checksum %s, want the checksum of the gzip file %s", m.Checksum, want)
+	}
+}
+
+func TestManifestRecordsEachShard(t *testing.T) {
+	dir := t.TempDir()
+	res := runCLI(t, dir, nil, "-q", "-c", "5", "-s", "1", "--split", "2", "-o", "out.jsonl", "--manifest", "manifest.json", `{"a":"$u8"}`)
+	if res.code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", res.code, res.stderr)
+	}
+	m := readManifest(t, filepath.Join(dir, "manifest.json"))
+	want := []string{"out.0001.jsonl", "out.0002.jsonl", "out.0003.jsonl"}
+	if len(m.Outputs) != len(want) {
+		t.Fatalf("got %d outputs %v, want %v", len(m.Outputs), m.Outputs, want)
+	}
+	for z, output := range m.Outputs {
+		if output.Path != want[z] {
+			t.Errorf("output %d has path %q, want %q", z, output.Path, want[z])
+		}
+		if sum := fileSha256(t, filepath.Join(dir, want[z])); output.Checksum != sum {
+			t.Errorf("output %d has checksum %s, want %s", z, output.Checksum, sum)
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	const template = `{"a":"$u8","i":"$i"}`
+	tests := []struct {
+		name    string
+		args    []string
+		records int
+		output  string // output file, empty when writing to stdout only
+		seeded  bool
+	}{
+		{"file", []string{"-c", "4", "-s", "3"}, 4, "commands.jsonl", true},
+		{"array", []string{"-c", "2", "-s", "3", "--format", "array", "-o", "out.json"}, 2, "out.json", true},
+		{"no seed", []string{"-c", "3"}, 3, "commands.jsonl", false},
+		{"stdout", []string{"-c", "3", "-s", "3", "--no-file"}, 3, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			res := runCLI(t, dir, nil, append(append(tt.args, "--manifest", "manifest.json"), template)...)
+			if res.code != 0 {
+				t.Fatalf("exit code %d, stderr: %s", res.code, res.stderr)
+			}
+			m := readManifest(t, filepath.Join(dir, "manifest.json"))
+			if m.Records != tt.records || m.Count != tt.records {
+				t.Errorf("manifest has %d records of count %d, want %d", m.Records, m.Count, tt.records)
+			}
+			if m.TemplateHash != sha256Hex([]byte(template)) {
+				t.Errorf("template hash %s, want %s", m.TemplateHash, sha256Hex([]byte(template)))
+			}
+			if tt.seeded != (m.Seed != nil) || tt.seeded && *m.Seed != 3 {
+				t.Errorf("manifest seed %v, want it recorded: %v", m.Seed, tt.seeded)
+			}
+
+			if tt.output == "" {
+				if len(m.Outputs) != 0 || m.Checksum != sha256Hex([]byte(res.stdout)) {
+					t.Errorf("got outputs %v and checksum %s, want no outputs and the checksum of stdout", m.Outputs, m.Checksum)
+				}
+				return
+			}
+			sum := fileSha256(t, filepath.Join(dir, tt.output))
+			want := []OutputFile{{Path: tt.output, Checksum: sum}}
+			if !reflect.DeepEqual(m.Outputs, want) || m.Checksum != sum {
+				t.Errorf("got outputs %v and checksum %s, want %v", m.Outputs, m.Checksum, want)
+			}
+		})
+	}
+}
+
+func TestManifestIsReproducible(t *testing.T) {
+	checksum := func() string {
+		dir := t.TempDir()
+		res := runCLI(t, dir, nil, "-q", "-c", "10", "-s", "5", "--manifest", "manifest.json", `{"a":"$u32"}`)
+		if res.code != 0 {
+			t.Fatalf("exit code %d, stderr: %s", res.code, res.stderr)
+		}
+		return readManifest(t, filepath.Join(dir, "manifest.json")).Checksum
+	}
+	if first, second := checksum(), checksum(); first != second {
+		t.Errorf("runs with the same seed have checksums %s and %s", first, second)
+	}
+}
+
+func TestManifestWriteError(t *testing.T) {
+	dir := t.TempDir()
+	res := runCLI(t, dir, nil, "--no-file", "--manifest", filepath.Join(dir, "missing", "manifest.json"), `{"a":1}`)
+	if res.code != 1 || !strings.Contains(res.stderr, "Error writing manifest") {
+		t.Errorf("exit code %d, stderr %q; want the manifest write to fail", res.code, res.stderr)
+	}
+}
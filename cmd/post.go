@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// httpSink POSTs records to a URL from a fixed number of goroutines.
+type httpSink struct {
+	client  *http.Client
+	url     string
+	headers http.Header
+	bodies  chan []byte
+	wg      sync.WaitGroup
+	sent    atomic.Int64
+	failed  atomic.Int64 // requests that errored or got a non-2xx response
+}
+
+// parseHeaders parses --header values of the form "Name: value".
+func parseHeaders(values []string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, v := range values {
+		name, value, ok := strings.Cut(v, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q (expected \"Name: value\")", v)
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+func newHTTPSink(url string, headers http.Header, concurrency int) *httpSink {
+	s := &httpSink{
+		client:  &http.Client{},
+		url:     url,
+		headers: headers,
+		bodies:  make(chan []byte, concurrency),
+	}
+	for z := 0; z < concurrency; z++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for body := range s.bodies {
+				s.post(body)
+			}
+		}()
+	}
+	return s
+}
+
+func (s *httpSink) post(body []byte) {
+	s.sent.Add(1)
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error posting record: %s\n", err)
+		s.failed.Add(1)
+		return
+	}
+	req.Header = s.headers.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error posting record: %s\n", err)
+		s.failed.Add(1)
+		return
+	}
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		s.failed.Add(1)
+	}
+}
+
+// send queues body to be posted, blocking while all goroutines are busy.
+func (s *httpSink) send(body []byte) {
+	s.bodies <- body
+}
+
+// close waits for the queued records to be posted and returns how many
+// requests were sent and how many of them failed.
+func (s *httpSink) close() (sent, failed int64) {
+	close(s.bodies)
+	s.wg.Wait()
+	return s.sent.Load(), s.failed.Load()
+}
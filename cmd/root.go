@@ -1,14 +1,26 @@
 package cmd
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"math/rand/v2"
 	"os"
-	"reflect"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	texttemplate "text/template"
+	"time"
 
+	"github.com/okonomipizza/rjg/pkg/generator"
 	"github.com/spf13/cobra"
 )
 
@@ -16,337 +28,526 @@ var rootCmd = &cobra.Command{
 	Use:   "rjg",
 	Short: "Generate JSON values based on the provided template.",
 	Long:  `Generate structured JSON values using specified variables and a JSON template.`,
-	Args:  cobra.MinimumNArgs(1), // JSON template must be needed
+	Args: func(cmd *cobra.Command, args []string) error {
+		// JSON template must be needed, unless it is read from a file or
+		// derived from a schema
+		if cmd.Flags().Changed("template-file") || cmd.Flags().Changed("schema") {
+			if cmd.Flags().Changed("template-file") && cmd.Flags().Changed("schema") {
+				return errors.New("--schema cannot be combined with --template-file")
+			}
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	PreRun: func(cmd *cobra.Command, args []string) {
+		if argsData.schema != "" {
+			template, err := schemaTemplate(argsData.schema, argsData.prefix)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading schema %q: %s\n", argsData.schema, err)
+				os.Exit(1)
+			}
+			argsData.template = template
+			return
+		}
+		if argsData.templateFile != "" {
+			data, err := os.ReadFile(argsData.templateFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading template file %q: %s\n", argsData.templateFile, err)
+				os.Exit(1)
+			}
+			argsData.template = string(data)
+			return
+		}
 		argsData.template = args[len(args)-1]
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		file, err := os.Create("commands.jsonl")
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error opening file: %s\n", err)
+		if err := applyEnv(cmd, &argsData); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		// --indent only applies to pretty output
+		indent := ""
+		if argsData.pretty {
+			if argsData.indent == "" {
+				fmt.Fprintln(os.Stderr, "Error: --indent must not be empty")
+				os.Exit(1)
+			}
+			indent = argsData.indent
+		}
+		if _, err := generator.NewEncoder(generator.Format(argsData.format), indent); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		arrayOutput := argsData.format == string(generator.FormatArray) || argsData.pretty
+		// An endless run can only stream, since it never gets to close a
+		// file or a JSON array
+		if argsData.count < 0 {
+			if arrayOutput {
+				fmt.Fprintln(os.Stderr, "Error: --count -1 cannot be used with array output (--format array or --pretty)")
+				os.Exit(1)
+			}
+			if !argsData.noFile && argsData.output != "-" {
+				fmt.Fprintln(os.Stderr, `Error: --count -1 requires --no-file or --output "-"`)
+				os.Exit(1)
+			}
+		}
+		// A closed JSON array cannot be extended by appending records
+		if arrayOutput && argsData.append {
+			fmt.Fprintln(os.Stderr, "Error: --append cannot be used with array output (--format array or --pretty)")
 			os.Exit(1)
 		}
-		defer file.Close()
 
-		var template interface{} // json template to be outputed
-		err = json.Unmarshal([]byte(argsData.template), &template)
+		templateText, err := applyTemplateParams(argsData.template, argsData.params)
 		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid template params: %s\n", err)
+			os.Exit(1)
+		}
+
+		if _, err := generator.ParseTemplate([]byte(templateText)); err != nil {
 			fmt.Printf("Error: Invalid JSON template: %s\n", err)
 			return
 		}
 
-		generator := newGenerator(argsData.variables)
+		for k, v := range argsData.variables {
+			if _, err := generator.ParseTemplate([]byte(v)); err != nil {
+				if argsData.strictVars {
+					fmt.Fprintf(os.Stderr, "Error: Failed to parse user variable %q: %s\n", k, err)
+					os.Exit(1)
+				}
+				fmt.Printf("WARNING: Failed to parse user variable %q, storing as string: %s\n", k, err)
+			}
+		}
 
-		for i := 0; i < argsData.count; i++ {
-			// Generate json data
-			result, err := generator.Generate(i, template)
+		if argsData.maxBytes < 0 {
+			fmt.Fprintln(os.Stderr, "Error: --max-bytes must not be negative")
+			os.Exit(1)
+		}
+		if argsData.workers < 1 {
+			fmt.Fprintln(os.Stderr, "Error: --workers must be at least 1")
+			os.Exit(1)
+		}
+		opts := []generator.Option{generator.WithCount(argsData.count), generator.WithPrefix(argsData.prefix), generator.WithMaxDepth(argsData.maxDepth)}
+		if argsData.preserveOrder {
+			opts = append(opts, generator.WithPreserveOrder())
+		}
+		if argsData.varsFile != "" {
+			data, err := os.ReadFile(argsData.varsFile)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error during generating: %s\n", err)
+				fmt.Fprintf(os.Stderr, "Error reading vars file %q: %s\n", argsData.varsFile, err)
 				os.Exit(1)
 			}
-
-			// Encode json
-			jsonOutput, err := json.Marshal(result)
+			parsed, err := generator.ParseTemplate(data)
 			if err != nil {
-				fmt.Println("JSON encode error:", err)
+				fmt.Fprintf(os.Stderr, "Error: Invalid vars file %q: %s\n", argsData.varsFile, err)
 				os.Exit(1)
 			}
-
-			// Write to file
-			_, err = file.WriteString(string(jsonOutput) + "\n")
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error writing to file: %s\n", err)
+			fileVars, ok := parsed.(map[string]interface{})
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Error: Invalid vars file %q: must contain a JSON object\n", argsData.varsFile)
 				os.Exit(1)
 			}
-
-			// Write to stdout
-			fmt.Println(string(jsonOutput))
+			opts = append(opts, generator.WithVars(fileVars))
 		}
-
-	},
-}
-
-func Execute() {
-	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
-	}
-}
-
-func init() {
-	rootCmd.PersistentFlags().IntVarP(&argsData.count, "count", "c", 1, "NUmber of JSON values to generate")
-	rootCmd.PersistentFlags().StringToStringVarP(&argsData.variables, "var", "v", map[string]string{}, "Key-value pairs for variables")
-}
-
-type Args struct {
-	count     int
-	variables map[string]string
-	template  string
-}
-
-var argsData Args
-
-type Generator struct {
-	prefix         string
-	predefinedVars map[string]func() interface{}
-	vars           map[string]interface{}
-}
-
-var prefixed = map[string]bool{
-	"int":    true,
-	"str":    true,
-	"arr":    true,
-	"obj":    true,
-	"oneof":  true,
-	"option": true,
-	"i":      true,
-	"u8":     true,
-	"u16":    true,
-	"u32":    true,
-	"i8":     true,
-	"i16":    true,
-	"i32":    true,
-	"i64":    true,
-	"digit":  true,
-	"bool":   true,
-	"alpha":  true,
-}
-
-func isPredefinedVar(value string) bool {
-	return prefixed[value]
-}
-
-func newGenerator(userVars map[string]string) Generator {
-	g := Generator{
-		prefix:         "$",
-		predefinedVars: nil,
-		vars:           make(map[string]interface{}),
-	}
-	for k, v := range userVars {
-		var parsedValue interface{}
-		err := json.Unmarshal([]byte(v), &parsedValue)
-		if err != nil {
-			fmt.Printf("WARNING: Failed to parse user variable %q, storing as string: %s\n", k, err)
-			parsedValue = v
-		}
-		g.vars[k] = parsedValue
-	}
-
-	return g
-}
-
-func (g Generator) Generate(i int, template interface{}) (interface{}, error) {
-	switch t := template.(type) {
-	case map[string]interface{}:
-		generated := make(map[string]interface{})
-		for key, val := range t {
-			// handle generator and return generated value
-			if isPredefinedVar(strings.TrimPrefix(key, g.prefix)) {
-				result, err := g.resolveVar(g.prefix, key, val, i)
-				if err != nil {
-					return nil, fmt.Errorf("failed to resolve generator %q: %w", key, err)
+		// Each worker has its own generator; with a seed, worker w draws
+		// from its own stream so that a run is reproducible with the same
+		// number of workers. A single worker matches a sequential run.
+		plans := make([]*generator.Plan, argsData.workers)
+		for w := range plans {
+			workerOpts := opts
+			if cmd.Flags().Changed("seed") {
+				seed := uint64(argsData.seed)
+				workerOpts = append(opts[:len(opts):len(opts)], generator.WithSource(rand.NewPCG(seed, seed+uint64(w))))
+			}
+			gen, err := generator.New(argsData.variables, workerOpts...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			// The template is parsed again by the generator to record its
+			// key order
+			template, err := gen.Parse([]byte(templateText))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Invalid JSON template: %s\n", err)
+				os.Exit(1)
+			}
+			if w == 0 {
+				if err := gen.Validate(template); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: Invalid template:\n%s\n", err)
+					os.Exit(1)
+				}
+				// Generators with state across records would each see only
+				// the records of their own worker
+				if stateful := gen.StatefulGenerators(template); len(plans) > 1 && len(stateful) > 0 {
+					fmt.Fprintf(os.Stderr, "Error: --workers cannot be used with %s, which keep state across records\n", strings.Join(stateful, ", "))
+					os.Exit(1)
 				}
-				return result, nil
 			}
-
-			// handle template_json
-			resolvedKey, err := g.Generate(i, key)
+			plans[w], err = gen.Compile(template)
 			if err != nil {
-				return nil, fmt.Errorf("failed to resolve key %q: %w", key, err)
+				fmt.Fprintf(os.Stderr, "Error: Invalid template: %s\n", err)
+				os.Exit(1)
 			}
-			resolvedVal, err := g.Generate(i, val)
+		}
+		if argsData.validateOnly {
+			fmt.Fprintln(os.Stderr, "Template is valid")
+			return
+		}
+		// A sample is the first record of a run, printed without writing
+		// any file
+		if argsData.sample {
+			result, err := plans[0].Generate(0)
 			if err != nil {
-				return nil, fmt.Errorf("failed to resolve val %q: %w", val, err)
+				fmt.Fprintf(os.Stderr, "Error during generating: %s\n", err)
+				os.Exit(1)
 			}
-
-			if strKey, ok := resolvedKey.(string); ok {
-				generated[strKey] = resolvedVal
-			} else {
-				return nil, errors.New("keys must resolve to strings")
+			data, err := json.MarshalIndent(result, "", argsData.indent)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error encoding record: %s\n", err)
+				os.Exit(1)
 			}
+			fmt.Println(string(data))
+			return
 		}
-		return generated, nil
 
-	case string:
-		generated, err := g.resolveVar(g.prefix, t, nil, i)
-		if err != nil {
-			return nil, fmt.Errorf("failed to resolve variable %q: %w", t, err)
+		var schema interface{}
+		if argsData.validateSchema != "" {
+			data, err := os.ReadFile(argsData.validateSchema)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading schema %q: %s\n", argsData.validateSchema, err)
+				os.Exit(1)
+			}
+			if schema, err = generator.ParseTemplate(data); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Invalid schema %q: %s\n", argsData.validateSchema, err)
+				os.Exit(1)
+			}
 		}
-		return generated, nil
-	default:
-		return template, nil
-	}
-}
 
-func (g Generator) resolveVar(prefix string, variable string, params interface{}, i int) (interface{}, error) {
-	trimmedVar := strings.TrimPrefix(variable, prefix)
-	switch trimmedVar {
-	case "int":
-		if paramsMap, ok := params.(map[string]interface{}); ok {
-			min, minOk := convertToInt(paramsMap["min"])
-			max, maxOk := convertToInt(paramsMap["max"])
-			if !minOk || !maxOk {
-				return nil, errors.New("invalid min or max value for $int")
-			}
-			return rand.IntN(max-min+1) + min, nil
-		}
-		return nil, errors.New("$int requires a {min, max} object")
-	case "str":
-		if paramsList, ok := params.([]interface{}); ok {
-			var strBuilder strings.Builder
-			for _, elem := range paramsList {
-				resolved, err := g.Generate(i, elem)
-				if err != nil {
-					return nil, err
-				}
-				strBuilder.WriteString(fmt.Sprintf("%v", resolved))
+		var sink *httpSink
+		if argsData.post != "" {
+			if argsData.concurrency < 1 {
+				fmt.Fprintln(os.Stderr, "Error: --concurrency must be at least 1")
+				os.Exit(1)
 			}
-			return strBuilder.String(), nil
+			headers, err := parseHeaders(argsData.headers)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			sink = newHTTPSink(argsData.post, headers, argsData.concurrency)
 		}
 
-		// In case of params is not an array, just a object
-		result, err := g.Generate(i, params)
-		if err != nil {
-			return nil, err
+		// Records go to stdout unless --quiet is set, and also to the
+		// output file unless --no-file is set or the output path is "-"
+		var stdout io.Writer = os.Stdout
+		if argsData.quiet {
+			stdout = io.Discard
 		}
-		resultStr, err := joinAnySlice(result)
-		if err != nil {
-			return nil, err
+		toFile := !argsData.noFile && argsData.output != "-"
+		if argsData.split < 0 || argsData.split > 0 && !toFile {
+			fmt.Fprintln(os.Stderr, "Error: --split requires a positive record count and an output file")
+			os.Exit(1)
 		}
-		return resultStr, nil
-
-	case "arr":
-		if paramsMap, ok := params.(map[string]interface{}); ok {
-			resolvedLen, err := g.Generate(i, paramsMap["len"])
-			if err != nil {
-				return nil, fmt.Errorf("failed to resolve length for $arr: %w", err)
+		basePath := argsData.output
+		if argsData.gzip && !cmd.Flags().Changed("output") {
+			basePath += ".gz"
+		}
+		var outputs []string
+		// The checksum covers the bytes that reach the output file, after
+		// compression, or the bytes written to stdout if there is no file
+		outputHash := sha256.New()
+		var fileHash hash.Hash       // checksum of the open output file
+		var outputFiles []OutputFile // closed output files, for the manifest
+		var closers []io.Closer      // closers of the open output file, in order
+		// openOutput opens the output file at path and returns the writer
+		// for the records, which also echoes them to stdout
+		openOutput := func(path string) io.Writer {
+			flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+			if argsData.append {
+				flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
 			}
-
-			length, ok := convertToInt(resolvedLen)
-			if !ok {
-				return nil, errors.New("invalid len value for $arr")
+			file, err := os.OpenFile(path, flags, 0o644)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening file %q: %s\n", path, err)
+				os.Exit(1)
 			}
-
-			val, valExists := paramsMap["val"]
-			if !valExists {
-				return nil, errors.New("missing val for $arr")
+			// Only the file is compressed; stdout stays plain text
+			fileHash = sha256.New()
+			var fileOut io.Writer = io.MultiWriter(file, outputHash, fileHash)
+			closers = nil
+			if argsData.gzip {
+				gz := gzip.NewWriter(fileOut)
+				fileOut = gz
+				closers = append(closers, gz)
 			}
-
-			arr := make([]interface{}, length)
-			for z := 0; z < length; z++ {
-				resolvedVal, err := g.Generate(i, val)
-				if err != nil {
-					return nil, err
+			closers = append(closers, file)
+			outputs = append(outputs, path)
+			return io.MultiWriter(fileOut, stdout)
+		}
+		closeOutput := func() {
+			for _, c := range closers {
+				if err := c.Close(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error closing output: %s\n", err)
+					os.Exit(1)
 				}
-				arr[z] = resolvedVal
 			}
-			return arr, nil
-
+			closers = nil
+			if fileHash != nil {
+				outputFiles = append(outputFiles, OutputFile{Path: outputs[len(outputs)-1], Checksum: hex.EncodeToString(fileHash.Sum(nil))})
+				fileHash = nil
+			}
 		}
-		return nil, errors.New("$arr requires a {len, val} object")
-	case "obj":
-		if paramsList, ok := params.([]interface{}); ok && len(paramsList) > 0 {
-
-			randomIndex := rand.IntN(len(paramsList))
-			selectedObj, ok := paramsList[randomIndex].(map[string]interface{})
-			if !ok {
-				return nil, errors.New("$obj must contain a list of objects")
+		shard := 1 // number of the output file being written under --split
+		out := stdout
+		if toFile {
+			path := basePath
+			if argsData.split > 0 {
+				path = shardPath(basePath, shard)
 			}
-			result, err := g.Generate(i, selectedObj)
-			if err != nil {
+			out = openOutput(path)
+		} else {
+			out = io.MultiWriter(out, outputHash)
+		}
+		// Batch the per-record writes; flushed before closing the outputs
+		// and before exiting on an error
+		bw := bufio.NewWriterSize(out, 64*1024)
+
+		writeOpts := generator.WriteOptions{Indent: indent, MaxBytes: argsData.maxBytes, Split: argsData.split}
+		// Under --split, finish the full output file and move on to the
+		// next one
+		writeOpts.NextOutput = func() (io.Writer, error) {
+			if err := bw.Flush(); err != nil {
 				return nil, err
 			}
-			return result, nil
-
+			closeOutput()
+			shard++
+			bw.Reset(openOutput(shardPath(basePath, shard)))
+			return bw, nil
 		}
-		return nil, errors.New("$obj requires objects")
-	case "oneof":
-		if paramsList, ok := params.([]interface{}); ok {
-			randomIndex := rand.IntN(len(paramsList))
-			oneof := paramsList[randomIndex]
-			resolved, err := g.Generate(i, oneof)
-			if err != nil {
-				return nil, err
+		if len(plans) > 1 {
+			done := make(chan struct{})
+			defer close(done)
+			writeOpts.Generate = generateParallel(plans, argsData.count, done)
+		}
+		if schema != nil {
+			writeOpts.Check = func(i int, v interface{}) error {
+				if err := generator.ValidateSchema(schema, v); err != nil {
+					return &schemaError{record: i, err: err}
+				}
+				return nil
 			}
-			return resolved, nil
 		}
-		return nil, errors.New("$oneof requires a list of values")
-	case "option":
-		if params == nil {
-			return nil, errors.New("$option requires a valid parameter")
+		if argsData.continueOnError {
+			writeOpts.Skip = func(i int, err error) bool {
+				var se *schemaError
+				if errors.As(err, &se) {
+					fmt.Fprintf(os.Stderr, "Record %d does not conform to the schema, skipping: %s\n", i, se.err)
+				} else {
+					fmt.Fprintf(os.Stderr, "Error during generating record %d, skipping: %s\n", i, err)
+				}
+				return true
+			}
+		}
+		start := time.Now()
+		lastFlush := start
+		writeOpts.Written = func(v interface{}) error {
+			// Endless runs are consumed as they go, so do not hold records
+			// in the buffer for long
+			if argsData.count < 0 && time.Since(lastFlush) >= 100*time.Millisecond {
+				if err := bw.Flush(); err != nil {
+					return err
+				}
+				lastFlush = time.Now()
+			}
+			if sink != nil {
+				body, err := json.Marshal(v)
+				if err != nil {
+					return fmt.Errorf("failed to encode record: %w", err)
+				}
+				sink.send(body)
+			}
+			return nil
 		}
 
-		oneofParams := []interface{}{params}
-		result, err := g.Generate(i, map[string]interface{}{"$oneof": oneofParams})
-		if err != nil {
-			return nil, err
+		// On SIGINT or SIGTERM, finish the current record and close the
+		// output properly instead of dying mid-write
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		res, err := plans[0].WriteRecords(ctx, bw, argsData.count, generator.Format(argsData.format), writeOpts)
+		interrupted := ctx.Err() != nil && errors.Is(err, ctx.Err())
+		if err != nil && !interrupted {
+			bw.Flush()
+			var se *schemaError
+			if errors.As(err, &se) {
+				fmt.Fprintf(os.Stderr, "Error: record %d does not conform to the schema: %s\n", se.record, se.err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Error during generating: %s\n", err)
+			}
+			os.Exit(1)
+		}
+		if err := bw.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %s\n", err)
+			os.Exit(1)
+		}
+		closeOutput()
+		records := res.Records
+		var postFailed int64
+		if sink != nil {
+			var sent int64
+			sent, postFailed = sink.close()
+			fmt.Fprintf(os.Stderr, "Posted %d record(s) to %s, %d failed\n", sent, argsData.post, postFailed)
+		}
+		if res.Skipped > 0 {
+			fmt.Fprintf(os.Stderr, "Skipped %d record(s) due to errors\n", res.Skipped)
+		}
+		if argsData.stats {
+			printStats(os.Stderr, records, outputs, time.Since(start))
 		}
-		return result, nil
 
-	case "i":
-		return i, nil // return iteration value
-	case "u8":
-		return uint8(rand.UintN(256)), nil
-	case "u16":
-		return uint16(rand.UintN(65536)), nil
-	case "u32":
-		return rand.Uint32(), nil
-	case "i8":
-		return int8(rand.IntN(256) - 128), nil
-	case "i16":
-		return int16(rand.IntN(65536) - 32768), nil
-	case "i32":
-		return rand.Int32(), nil
-	case "i64":
-		return rand.Int64(), nil
-	case "digit":
-		return rand.IntN(10), nil
-	case "bool":
-		return rand.IntN(2) == 1, nil
-	case "alpha":
-		if rand.IntN(2) == 0 {
-			return string(rune('a' + rand.IntN(26))), nil
-		}
-		return string(rune('A' + rand.IntN(26))), nil
-	default:
-		if strings.HasPrefix(variable, prefix) {
-			// handle user-defined variables
-			if userdefinedVar, isExist := g.vars[strings.TrimPrefix(variable, prefix)]; isExist {
-				result, err := g.Generate(i, userdefinedVar)
-				if err != nil {
-					return nil, fmt.Errorf("failed to resolve variable %q: %w", variable, err)
-				}
-				return result, nil
+		if argsData.manifest != "" {
+			manifest := Manifest{
+				Count:        argsData.count,
+				TemplateHash: sha256Hex([]byte(argsData.template)),
+				Outputs:      outputFiles,
+				Records:      records,
+				Checksum:     hex.EncodeToString(outputHash.Sum(nil)),
+			}
+			if cmd.Flags().Changed("seed") {
+				manifest.Seed = &argsData.seed
+			}
+			if err := writeManifest(argsData.manifest, manifest); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing manifest: %s\n", err)
+				os.Exit(1)
 			}
-			return nil, fmt.Errorf("undefined variable: %q", variable)
 		}
-		return variable, nil
-	}
+		if interrupted {
+			fmt.Fprintf(os.Stderr, "Interrupted, stopped after %d record(s)\n", records)
+			os.Exit(1)
+		}
+		if postFailed > 0 && argsData.failOnHTTPError {
+			os.Exit(1)
+		}
+	},
 }
 
-func convertToInt(value interface{}) (int, bool) {
-	switch v := value.(type) {
-	case float64:
-		return int(v), true
-	case int:
-		return v, true
-	default:
-		return 0, false
+func Execute() {
+	err := rootCmd.Execute()
+	if err != nil {
+		os.Exit(1)
 	}
 }
 
-func joinAnySlice(result interface{}) (string, error) {
-	v := reflect.ValueOf(result)
+func init() {
+	rootCmd.PersistentFlags().IntVarP(&argsData.count, "count", "c", 1, "NUmber of JSON values to generate (-1 generates until interrupted; defaults to $RJG_COUNT)")
+	rootCmd.PersistentFlags().StringToStringVarP(&argsData.variables, "var", "v", map[string]string{}, "Key-value pairs for variables (RJG_VAR_<NAME> environment variables define more)")
+	rootCmd.PersistentFlags().StringVarP(&argsData.templateFile, "template-file", "f", "", "Read the JSON template from this file instead of the arguments")
+	rootCmd.PersistentFlags().StringVarP(&argsData.output, "output", "o", "commands.jsonl", `Output file path ("-" writes to stdout only)`)
+	rootCmd.PersistentFlags().BoolVar(&argsData.gzip, "gzip", false, `Compress the output file with gzip, adding ".gz" to the default file name`)
+	rootCmd.PersistentFlags().BoolVar(&argsData.append, "append", false, "Append records to the output file instead of replacing it")
+	rootCmd.PersistentFlags().BoolVar(&argsData.noFile, "no-file", false, "Write records to stdout only, without creating an output file")
+	rootCmd.PersistentFlags().StringVar(&argsData.varsFile, "vars-file", "", "Read variables from a JSON object file (--var takes precedence)")
+	rootCmd.PersistentFlags().StringVar(&argsData.format, "format", "jsonl", "Output format: jsonl, array, csv, yaml, or toml")
+	rootCmd.PersistentFlags().BoolVar(&argsData.pretty, "pretty", false, "Write indented JSON as a single array instead of JSONL")
+	rootCmd.PersistentFlags().BoolVar(&argsData.continueOnError, "continue-on-error", false, "Skip records that fail to generate instead of exiting")
+	rootCmd.PersistentFlags().BoolVar(&argsData.validateOnly, "validate-only", false, "Validate the template and exit without generating output")
+	rootCmd.PersistentFlags().Int64VarP(&argsData.seed, "seed", "s", 0, "Seed for the random generator to make output reproducible")
+	rootCmd.PersistentFlags().StringToStringVar(&argsData.params, "param", map[string]string{}, "Key-value pairs substituted into {{.key}} placeholders before the template is parsed")
+	rootCmd.PersistentFlags().BoolVar(&argsData.stats, "stats", false, "Print the record count, output size, and throughput to stderr after the run")
+	rootCmd.PersistentFlags().StringVar(&argsData.post, "post", "", "POST each record as JSON to this URL")
+	rootCmd.PersistentFlags().IntVar(&argsData.concurrency, "concurrency", 1, "Number of concurrent requests for --post")
+	rootCmd.PersistentFlags().StringArrayVarP(&argsData.headers, "header", "H", nil, `Extra header for --post requests, as "Name: value" (repeatable)`)
+	rootCmd.PersistentFlags().BoolVar(&argsData.failOnHTTPError, "fail-on-http-error", false, "Exit with a non-zero code if any --post request fails or gets a non-2xx response")
+	rootCmd.PersistentFlags().BoolVarP(&argsData.quiet, "quiet", "q", false, "Do not echo records to stdout")
+	rootCmd.PersistentFlags().BoolVar(&argsData.preserveOrder, "preserve-order", false, "Write object fields in template order instead of sorted by key")
+	rootCmd.PersistentFlags().StringVar(&argsData.schema, "schema", "", "Generate instances of a JSON Schema file instead of using a template")
+	rootCmd.PersistentFlags().StringVar(&argsData.validateSchema, "validate-schema", "", "Check each record against a JSON Schema file before writing it")
+	rootCmd.PersistentFlags().IntVar(&argsData.split, "split", 0, "Start a new numbered output file every N records (e.g. commands.0001.jsonl)")
+	rootCmd.PersistentFlags().StringVar(&argsData.indent, "indent", "  ", "Indentation for --pretty and --sample output")
+	rootCmd.PersistentFlags().BoolVar(&argsData.sample, "sample", false, "Print one pretty-printed record to stdout and exit without writing a file")
+	rootCmd.PersistentFlags().BoolVar(&argsData.strictVars, "strict-vars", false, "Fail on user variables that are not valid JSON instead of storing them as strings")
+	rootCmd.PersistentFlags().StringVar(&argsData.manifest, "manifest", "", "Write a JSON manifest with run parameters and an output checksum to this file")
+	rootCmd.PersistentFlags().StringVar(&argsData.prefix, "prefix", "$", "Prefix that marks generators and variables in the template")
+	rootCmd.PersistentFlags().IntVar(&argsData.maxDepth, "max-depth", 100, "Maximum depth of nested user-defined variable expansion")
+	rootCmd.PersistentFlags().IntVar(&argsData.workers, "workers", 1, "Number of goroutines generating records in parallel (output stays in order; cannot be used with $seq, $incr, $unique, $register, or $edge)")
+	rootCmd.PersistentFlags().Int64Var(&argsData.maxBytes, "max-bytes", 0, "Stop generating before the output exceeds this many bytes (0 means no limit)")
+}
+
+type Args struct {
+	count           int
+	variables       map[string]string
+	template        string
+	maxBytes        int64
+	params          map[string]string
+	manifest        string
+	seed            int64
+	output          string
+	noFile          bool
+	templateFile    string
+	varsFile        string
+	pretty          bool
+	format          string
+	validateOnly    bool
+	continueOnError bool
+	prefix          string
+	maxDepth        int
+	gzip            bool
+	append          bool
+	workers         int
+	stats           bool
+	post            string
+	concurrency     int
+	headers         []string
+	failOnHTTPError bool
+	quiet           bool
+	preserveOrder   bool
+	strictVars      bool
+	sample          bool
+	schema          string
+	validateSchema  string
+	split           int
+	indent          string
+}
+
+var argsData Args
 
-	if v.Kind() != reflect.Slice {
-		return "", fmt.Errorf("expected slice but got %T", result)
+// applyTemplateParams expands text/template placeholders such as {{.env}} in
+// the raw template before it is parsed as JSON. The pass only runs when at
+// least one --param is given, so templates containing literal "{{" are left
+// alone otherwise; with params, a literal "{{" can be written as {{"{{"}}.
+func applyTemplateParams(raw string, params map[string]string) (string, error) {
+	if len(params) == 0 {
+		return raw, nil
+	}
+	tmpl, err := texttemplate.New("template").Option("missingkey=error").Parse(raw)
+	if err != nil {
+		return "", err
 	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, params); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
 
-	var strSlice []string
-	for i := 0; i < v.Len(); i++ {
-		elem := v.Index(i)
-		strSlice = append(strSlice, fmt.Sprint(elem.Interface()))
+// shardPath returns the path of the n-th output file under --split, which
+// numbers the output path before its extension: commands.jsonl becomes
+// commands.0001.jsonl, and commands.jsonl.gz becomes commands.0001.jsonl.gz.
+func shardPath(path string, n int) string {
+	gz := ""
+	if strings.HasSuffix(path, ".gz") {
+		path, gz = strings.TrimSuffix(path, ".gz"), ".gz"
 	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%04d%s%s", strings.TrimSuffix(path, ext), n, ext, gz)
+}
+
+// schemaError is a record that does not conform to the --validate-schema
+// schema.
+type schemaError struct {
+	record int
+	err    error
+}
 
-	return strings.Join(strSlice, ""), nil
+func (e *schemaError) Error() string {
+	return fmt.Sprintf("record %d does not conform to the schema: %s", e.record, e.err)
 }
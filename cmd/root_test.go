@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/okonomipizza/rjg/pkg/generator"
+)
+
+// TestMain runs the test binary as rjg itself when RJG_TEST_CLI is set, so
+// that each CLI test gets a fresh process with fresh flags.
+func TestMain(m *testing.M) {
+	if os.Getenv("RJG_TEST_CLI") == "1" {
+		rootCmd.SetArgs(os.Args[1:])
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// cliResult is the outcome of a run of rjg.
+type cliResult struct {
+	stdout, stderr string
+	code           int
+}
+
+// runCLI runs rjg with args in dir, with env added to the environment.
+func runCLI(t *testing.T, dir string, env []string, args ...string) cliResult {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "RJG_TEST_CLI=1"), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("running rjg: %v", err)
+	}
+	return cliResult{stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()}
+}
+
+// lines splits output into its non-empty lines.
+func lines(output string) []string {
+	return strings.Split(strings.TrimRight(output, "\n"), "\n")
+}
+
+func TestCountGenerator(t *testing.T) {
+	res := runCLI(t, t.TempDir(), nil, "--no-file", "-c", "2", `{"n":"$count"}`)
+	if res.code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", res.code, res.stderr)
+	}
+	for _, line := range lines(res.stdout) {
+		if line != `{"n":2}` {
+			t.Errorf("got %s, want {\"n\":2}", line)
+		}
+	}
+}
+
+// BenchmarkOutput writes 100000 records to a file with and without the
+// buffering of the output loop.
+func BenchmarkOutput(b *testing.B) {
+	const count = 100000
+	g, err := generator.New(nil, generator.WithSource(rand.NewPCG(1, 1)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	template, err := g.Parse([]byte(`{"id":"$i","name":{"$alnum":{"len":8}},"ok":"$bool"}`))
+	if err != nil {
+		b.Fatal(err)
+	}
+	plan, err := g.Compile(template)
+	if err != nil {
+		b.Fatal(err)
+	}
+	run := func(b *testing.B, buffered bool) {
+		file, err := os.Create(filepath.Join(b.TempDir(), "out.jsonl"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer file.Close()
+		for b.Loop() {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				b.Fatal(err)
+			}
+			var w io.Writer = file
+			bw := bufio.NewWriterSize(file, 64*1024)
+			if buffered {
+				w = bw
+			}
+			if _, err := plan.WriteRecords(context.Background(), w, count, generator.FormatJSONL, generator.WriteOptions{}); err != nil {
+				b.Fatal(err)
+			}
+			if err := bw.Flush(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.Run("buffered", func(b *testing.B) { run(b, true) })
+	b.Run("unbuffered", func(b *testing.B) { run(b, false) })
+}
+
+func TestUniqueRunsOutOfValues(t *testing.T) {
+	res := runCLI(t, t.TempDir(), nil, "--no-file", "-q", "-c", "1000", `{"id":{"$unique":"$u8"}}`)
+	if res.code != 1 || !strings.Contains(res.stderr, "$unique: could not generate a value not seen before") {
+		t.Errorf("exit code %d, stderr %q; want $unique to run out of values", res.code, res.stderr)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		maxBytes int
+		records  int // records expected in the file
+	}{
+		{"jsonl", nil, 110, 10}, // records of 11 bytes each
+		{"jsonl partial record", nil, 115, 10},
+		{"count comes first", []string{"-c", "3"}, 110, 3},
+		{"array", []string{"--format", "array"}, 60, 4}, // 2 + 12 + 3*13 + 3 bytes
+		{"too small for any record", nil, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			args := append([]string{"-q", "-c", "100", "-o", "out", "--max-bytes", strconv.Itoa(tt.maxBytes)}, tt.args...)
+			res := runCLI(t, dir, nil, append(args, `{"i":{"$padIndex":{"width":2}}}`)...)
+			if res.code != 0 {
+				t.Fatalf("exit code %d, stderr: %s", res.code, res.stderr)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) > tt.maxBytes {
+				t.Errorf("output is %d bytes, over the cap of %d", len(data), tt.maxBytes)
+			}
+			var records []interface{}
+			if slices.Contains(tt.args, "array") {
+				if err := json.Unmarshal(data, &records); err != nil {
+					t.Fatalf("output is not a JSON array: %v\n%s", err, data)
+				}
+			} else {
+				for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+					if line != "" {
+						records = append(records, line)
+					}
+				}
+			}
+			if len(records) != tt.records {
+				t.Errorf("got %d records, want %d:\n%s", len(records), tt.records, data)
+			}
+		})
+	}
+}
+
+func TestMaxBytesNegative(t *testing.T) {
+	res := runCLI(t, t.TempDir(), nil, "--no-file", "--max-bytes", "-1", `{"a":1}`)
+	if res.code != 1 || !strings.Contains(res.stderr, "--max-bytes must not be negative") {
+		t.Errorf("exit code %d, stderr %q; want a negative --max-bytes to be rejected", res.code, res.stderr)
+	}
+}
+
+func TestApplyTemplateParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		params  map[string]string
+		want    string
+		wantErr string
+	}{
+		{"substitutes", `{"env":"{{.env}}","n":{{.n}}}`, map[string]string{"env": "prod", "n": "3"}, `{"env":"prod","n":3}`, ""},
+		{"no params leaves braces alone", `{"a":"{{.env}}"}`, nil, `{"a":"{{.env}}"}`, ""},
+		{"escaped braces", `{"a":"{{"{{"}}x}}","b":"{{.b}}"}`, map[string]string{"b": "1"}, `{"a":"{{x}}","b":"1"}`, ""},
+		{"missing param", `{"a":"{{.other}}"}`, map[string]string{"env": "prod"}, "", `map has no entry for key "other"`},
+		{"bad syntax", `{"a":{{.env`, map[string]string{"env": "prod"}, "", "unclosed action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyTemplateParams(tt.raw, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamFlag(t *testing.T) {
+	res := runCLI(t, t.TempDir(), nil, "--no-file", "--param", "env=prod", `{"env":"{{.env}}"}`)
+	if res.code != 0 || res.stdout != "{\"env\":\"prod\"}\n" {
+		t.Errorf("exit code %d, stdout %q, stderr %q; want the param substituted", res.code, res.stdout, res.stderr)
+	}
+	res = runCLI(t, t.TempDir(), nil, "--no-file", "--param", "env=prod", `{"env":"{{.missing}}"}`)
+	if res.code != 1 || !strings.Contains(res.stderr, "Invalid template params") {
+		t.Errorf("exit code %d, stderr %q; want a missing param to be an error", res.code, res.stderr)
+	}
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/okonomipizza/rjg/pkg/generator"
+)
+
+// schemaTemplate reads a JSON Schema file and returns the JSON text of a
+// template that generates instances of it. Skipped schema keywords are
+// reported as warnings.
+func schemaTemplate(path, prefix string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	schema, err := generator.ParseTemplate(data)
+	if err != nil {
+		return "", err
+	}
+	template, warnings, err := generator.SchemaTemplate(schema, prefix)
+	if err != nil {
+		return "", err
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
+	}
+	text, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// printStats writes a summary of a finished run to w.
+func printStats(w io.Writer, records int, outputs []string, elapsed time.Duration) {
+	fmt.Fprintf(w, "Records:  %d\n", records)
+	for _, path := range outputs {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(w, "Output:   %s (%d bytes)\n", path, info.Size())
+		}
+	}
+	fmt.Fprintf(w, "Elapsed:  %s\n", elapsed.Round(time.Millisecond))
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		fmt.Fprintf(w, "Rate:     %.0f records/s\n", float64(records)/seconds)
+	}
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import "github.com/okonomipizza/rjg/pkg/generator"
+
+// generated is a record produced by a worker, or the error generating it.
+type generated struct {
+	value interface{}
+	err   error
+}
+
+// generateParallel generates records 0 to count-1, or without end if count
+// is negative, with one goroutine per plan. Worker w generates the records
+// i with i%len(plans) == w, so the records of each worker only depend on
+// its own generator regardless of scheduling. The returned function yields
+// the records and must be called with i in increasing order. Closing done
+// stops the workers.
+func generateParallel(plans []*generator.Plan, count int, done <-chan struct{}) func(i int) (interface{}, error) {
+	chans := make([]chan generated, len(plans))
+	for w, plan := range plans {
+		ch := make(chan generated, 64)
+		chans[w] = ch
+		go func() {
+			defer close(ch)
+			for i := w; count < 0 || i < count; i += len(plans) {
+				value, err := plan.Generate(i)
+				select {
+				case ch <- generated{value, err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	return func(i int) (interface{}, error) {
+		r := <-chans[i%len(chans)]
+		return r.value, r.err
+	}
+}
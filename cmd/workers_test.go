@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWorkers(t *testing.T) {
+	const template = `{"a":"$u32","i":"$i"}`
+	run := func(args ...string) string {
+		t.Helper()
+		res := runCLI(t, t.TempDir(), nil, append([]string{"--no-file", "-c", "20", "-s", "7"}, append(args, template)...)...)
+		if res.code != 0 {
+			t.Fatalf("exit code %d, stderr: %s", res.code, res.stderr)
+		}
+		return res.stdout
+	}
+
+	sequential := run()
+	if one := run("--workers", "1"); one != sequential {
+		t.Errorf("one worker differs from a sequential run:\n%s\nwant:\n%s", one, sequential)
+	}
+	four := run("--workers", "4")
+	if again := run("--workers", "4"); again != four {
+		t.Errorf("runs with the same seed and number of workers differ:\n%s\nand:\n%s", four, again)
+	}
+	for z, line := range lines(four) {
+		if want := `"i":` + strconv.Itoa(z) + `}`; !strings.HasSuffix(line, want) {
+			t.Errorf("record %d out of order: %s", z, line)
+		}
+	}
+}
+
+func TestWorkersRejectStatefulGenerators(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		template string
+		want     string
+	}{
+		{"unique", nil, `{"id":{"$unique":"$u8"}}`, "$unique"},
+		{"seq", nil, `{"id":{"$seq":null}}`, "$seq"},
+		{"through a variable", []string{"-v", `id={"$incr":null}`}, `{"id":"$id"}`, "$incr"},
+		{"register and edge", nil, `{"a":{"$register":{"pool":"p","val":"$i"}},"b":{"$edge":{"from":"p","to":"p"}}}`, "$edge, $register"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append(append([]string{"--no-file", "-c", "10", "--workers", "2"}, tt.args...), tt.template)
+			res := runCLI(t, t.TempDir(), nil, args...)
+			if res.code != 1 || !strings.Contains(res.stderr, "--workers cannot be used with "+tt.want) {
+				t.Errorf("exit code %d, stderr %q; want a rejection naming %s", res.code, res.stderr, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cardNetwork describes the numbers issued by a card network.
+type cardNetwork struct {
+	prefixes []string
+	length   int
+	groups   []int // digits per space-separated group
+}
+
+var cardNetworks = map[string]cardNetwork{
+	"visa":       {prefixes: []string{"4"}, length: 16, groups: []int{4, 4, 4, 4}},
+	"mastercard": {prefixes: []string{"51", "52", "53", "54", "55", "2221", "2720"}, length: 16, groups: []int{4, 4, 4, 4}},
+	"amex":       {prefixes: []string{"34", "37"}, length: 15, groups: []int{4, 6, 5}},
+}
+
+// creditCard returns a card number of the given network, or a random one if
+// network is empty, ending in a valid Luhn check digit.
+func (g *Generator) creditCard(network string, grouped bool) (string, error) {
+	if network == "" {
+		names := sortedKeys(cardNetworks)
+		network = names[g.rng.IntN(len(names))]
+	}
+	n, ok := cardNetworks[strings.ToLower(network)]
+	if !ok {
+		return "", fmt.Errorf("unsupported network %q (supported: %s)", network, strings.Join(sortedKeys(cardNetworks), ", "))
+	}
+
+	prefix := n.prefixes[g.rng.IntN(len(n.prefixes))]
+	number := prefix + randomDigits(g.rng, n.length-len(prefix)-1)
+	number += string(rune('0' + luhnCheckDigit(number)))
+	if !grouped {
+		return number, nil
+	}
+	parts := make([]string, 0, len(n.groups))
+	for _, size := range n.groups {
+		parts = append(parts, number[:size])
+		number = number[size:]
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// luhnCheckDigit returns the digit that makes digits followed by it pass
+// the Luhn checksum.
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	// Walking from the right, every other digit starting with the last one
+	// is doubled, since the check digit will be appended after it
+	for z := len(digits) - 1; z >= 0; z-- {
+		d := int(digits[z] - '0')
+		if (len(digits)-1-z)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// luhnValid reports whether number passes the Luhn checksum.
+func luhnValid(number string) bool {
+	sum := 0
+	for z := len(number) - 1; z >= 0; z-- {
+		d := int(number[z] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if (len(number)-z)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func TestLuhnValid(t *testing.T) {
+	for _, number := range []string{"4111111111111111", "5555555555554444", "378282246310005"} {
+		if !luhnValid(number) {
+			t.Errorf("%s should pass the Luhn checksum", number)
+		}
+	}
+	if luhnValid("4111111111111112") {
+		t.Error("4111111111111112 should fail the Luhn checksum")
+	}
+}
+
+func TestCreditCard(t *testing.T) {
+	tests := []struct {
+		network  string
+		prefixes []string
+		length   int
+		groups   []int
+	}{
+		{"visa", []string{"4"}, 16, []int{4, 4, 4, 4}},
+		{"mastercard", []string{"51", "52", "53", "54", "55", "2221", "2720"}, 16, []int{4, 4, 4, 4}},
+		{"amex", []string{"34", "37"}, 15, []int{4, 6, 5}},
+		{"AMEX", []string{"34", "37"}, 15, []int{4, 6, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			for _, grouped := range []bool{false, true} {
+				template := `{"c":{"$creditcard":{"network":"` + tt.network + `","grouped":` + strconv.FormatBool(grouped) + `}}}`
+				for _, v := range field(generateRecords(t, template, 50, 1), "c") {
+					s := v.(string)
+					parts := strings.Split(s, " ")
+					if grouped {
+						if len(parts) != len(tt.groups) {
+							t.Fatalf("%q is not in %d groups", s, len(tt.groups))
+						}
+						for z, part := range parts {
+							if len(part) != tt.groups[z] {
+								t.Errorf("%q: group %d has %d digits, want %d", s, z, len(part), tt.groups[z])
+							}
+						}
+					} else if len(parts) != 1 {
+						t.Errorf("%q is grouped, want plain digits", s)
+					}
+					number := strings.Join(parts, "")
+					if len(number) != tt.length {
+						t.Errorf("%q has %d digits, want %d", s, len(number), tt.length)
+					}
+					if !hasAnyPrefix(number, tt.prefixes) {
+						t.Errorf("%q does not start with any of %v", s, tt.prefixes)
+					}
+					if !luhnValid(number) {
+						t.Errorf("%q fails the Luhn checksum", s)
+					}
+				}
+				assertDeterministic(t, template, 5)
+			}
+		})
+	}
+}
+
+func TestCreditCardRandomNetwork(t *testing.T) {
+	template := `{"c":"$creditcard"}`
+	seen := make(map[int]bool)
+	for _, v := range field(generateRecords(t, template, 100, 1), "c") {
+		number := v.(string)
+		if !luhnValid(number) {
+			t.Errorf("%q fails the Luhn checksum", number)
+		}
+		seen[len(number)] = true
+	}
+	// Amex numbers are 15 digits, Visa and Mastercard 16
+	if !seen[15] || !seen[16] {
+		t.Errorf("got lengths %v, want numbers from every network", seen)
+	}
+	assertDeterministic(t, template, 5)
+}
+
+func TestCreditCardErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"network":"discover"}`, `$creditcard: unsupported network "discover" (supported: amex, mastercard, visa)`},
+		{`{"network":1}`, "network for $creditcard must be a string"},
+		{`{"grouped":"yes"}`, "grouped for $creditcard must be a boolean"},
+		{`"visa"`, "$creditcard accepts an optional {network, grouped} object"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"c":{"$creditcard":`+tt.params+`}}`, tt.want)
+	}
+}
+
+// hasAnyPrefix reports whether s starts with one of prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
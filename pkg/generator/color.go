@@ -0,0 +1,20 @@
+package generator
+
+import "fmt"
+
+var cssColors = []string{
+	"black", "white", "red", "green", "blue", "yellow", "orange", "purple",
+	"pink", "brown", "gray", "cyan", "magenta", "lime", "navy", "teal",
+	"olive", "maroon", "silver", "gold", "coral", "salmon", "tomato", "crimson",
+	"indigo", "violet", "orchid", "plum", "khaki", "beige", "ivory", "lavender",
+	"turquoise", "aquamarine", "chocolate", "tan", "sienna", "skyblue",
+	"steelblue", "slategray", "seagreen", "forestgreen", "darkorange", "hotpink",
+}
+
+// hexColor returns a random "#rrggbb" color, or "#rrggbbaa" with alpha.
+func (g *Generator) hexColor(alpha bool) string {
+	if alpha {
+		return fmt.Sprintf("#%08x", g.rng.Uint32())
+	}
+	return fmt.Sprintf("#%06x", g.rng.UintN(1<<24))
+}
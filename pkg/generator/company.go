@@ -0,0 +1,48 @@
+package generator
+
+var (
+	companyWords = []string{
+		"Acme", "Apex", "Blue Ridge", "Brightline", "Cascade", "Summit", "Evergreen",
+		"Granite", "Harbor", "Ironwood", "Keystone", "Lakeside", "Meridian", "Northwind",
+		"Oakridge", "Pinnacle", "Redwood", "Silverline", "Stonebridge", "Vertex",
+	}
+	companyNouns = []string{
+		"Analytics", "Consulting", "Dynamics", "Foods", "Holdings", "Industries",
+		"Labs", "Logistics", "Media", "Partners", "Solutions", "Systems",
+		"Technologies", "Ventures", "Works",
+	}
+	companySuffixes = []string{"Inc", "LLC", "Ltd", "Corp", "Group", "Co"}
+
+	jobLevels = []string{"Junior", "Senior", "Lead", "Principal", "Chief", "Associate", "Head of"}
+	jobRoles  = []string{
+		"Software Engineer", "Data Analyst", "Product Manager", "Accountant",
+		"Designer", "Sales Representative", "Recruiter", "Marketing Specialist",
+		"Support Engineer", "Operations Manager", "Financial Analyst", "Architect",
+	}
+
+	departments = []string{
+		"Engineering", "Sales", "Marketing", "Finance", "Human Resources",
+		"Operations", "Legal", "Customer Support", "Product", "Research and Development",
+		"Information Technology", "Procurement",
+	}
+)
+
+// company returns a company name such as "Northwind Logistics Inc", with a
+// legal suffix unless suffix is false.
+func (g *Generator) company(suffix bool) string {
+	name := companyWords[g.rng.IntN(len(companyWords))] + " " + companyNouns[g.rng.IntN(len(companyNouns))]
+	if suffix {
+		name += " " + companySuffixes[g.rng.IntN(len(companySuffixes))]
+	}
+	return name
+}
+
+// jobTitle returns a job title such as "Senior Data Analyst". Half of the
+// titles have no level.
+func (g *Generator) jobTitle() string {
+	role := jobRoles[g.rng.IntN(len(jobRoles))]
+	if g.rng.IntN(2) == 0 {
+		return role
+	}
+	return jobLevels[g.rng.IntN(len(jobLevels))] + " " + role
+}
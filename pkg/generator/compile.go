@@ -0,0 +1,415 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"strings"
+)
+
+// node is a template compiled into a tree of resolvers, so the structure of
+// the template is only inspected once.
+type node interface {
+	resolve(g *Generator, i int) (interface{}, error)
+}
+
+// Plan is a template compiled for repeated generation.
+type Plan struct {
+	g    *Generator
+	root node
+}
+
+// Compile parses template once into a Plan, reporting structural errors
+// before any record is generated. The templates within generator parameters
+// are compiled too, and reused whenever a generator resolves them.
+func (g *Generator) Compile(template interface{}) (*Plan, error) {
+	g.caching = true
+	defer func() { g.caching = false }()
+	root, err := g.compile(template)
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{g: g, root: root}, nil
+}
+
+// compiledNode is a compiled object or list, kept together with the
+// template it was compiled from so that the address it is cached under is
+// not reused.
+type compiledNode struct {
+	template interface{}
+	node     node
+}
+
+// templateID returns the address identifying an object or list in the
+// compiled cache, or false for other values, which are cheap to compile.
+func templateID(template interface{}) (uintptr, bool) {
+	switch t := template.(type) {
+	case map[string]interface{}:
+		return mapID(t), true
+	case []interface{}:
+		if len(t) == 0 {
+			return 0, false
+		}
+		return reflect.ValueOf(t).Pointer(), true
+	default:
+		return 0, false
+	}
+}
+
+// cachedNode returns the node compiled ahead for template, if any.
+func (g *Generator) cachedNode(template interface{}) (node, bool) {
+	id, ok := templateID(template)
+	if !ok {
+		return nil, false
+	}
+	c, ok := g.compiled[id]
+	if !ok {
+		return nil, false
+	}
+	// A shorter list may share its first element with a compiled one
+	if list, ok := template.([]interface{}); ok && len(list) != len(c.template.([]interface{})) {
+		return nil, false
+	}
+	return c.node, true
+}
+
+// precompile compiles the templates within params ahead, for the generators
+// that resolve them. Parameters that are not templates, such as literal
+// strings or objects mixing a generator with other keys, are skipped.
+func (g *Generator) precompile(params interface{}) {
+	switch t := params.(type) {
+	case map[string]interface{}:
+		if _, err := g.compile(t); err != nil {
+			for _, v := range t {
+				g.precompile(v)
+			}
+		}
+	case []interface{}:
+		if _, err := g.compile(t); err != nil {
+			for _, v := range t {
+				g.precompile(v)
+			}
+		}
+	}
+}
+
+// Generate generates the i-th record of the plan.
+func (p *Plan) Generate(i int) (interface{}, error) {
+	result, err := p.root.resolve(p.g, i)
+	if result == omitted {
+		return nil, err
+	}
+	return result, err
+}
+
+// GenerateContext is like Generate but stops with the context's error once
+// ctx is done.
+func (p *Plan) GenerateContext(ctx context.Context, i int) (interface{}, error) {
+	p.g.ctx = ctx
+	defer func() { p.g.ctx = nil }()
+	return p.Generate(i)
+}
+
+// Stream generates records 0 to count-1 lazily, or records without end if
+// count is negative. Iteration stops after the first error, which is
+// yielded with a nil record, and once ctx is done, with the context's error.
+func (p *Plan) Stream(ctx context.Context, count int) iter.Seq2[interface{}, error] {
+	return func(yield func(interface{}, error) bool) {
+		for i := 0; count < 0 || i < count; i++ {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			result, err := p.GenerateContext(ctx, i)
+			if !yield(result, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (g *Generator) compile(template interface{}) (node, error) {
+	if n, ok := g.cachedNode(template); ok {
+		return n, nil
+	}
+	n, err := g.compileNode(template)
+	if err != nil || !g.caching {
+		return n, err
+	}
+	if id, ok := templateID(template); ok {
+		g.compiled[id] = compiledNode{template: template, node: n}
+	}
+	return n, nil
+}
+
+func (g *Generator) compileNode(template interface{}) (node, error) {
+	switch t := template.(type) {
+	case map[string]interface{}:
+		keys := sortedKeys(t)
+		for _, key := range keys {
+			if _, ok := g.generatorName(key); !ok {
+				continue
+			}
+			if len(t) > 1 {
+				return nil, fmt.Errorf("generator %q cannot be combined with other keys in the same object", key)
+			}
+			if g.caching {
+				g.precompile(t[key])
+			}
+			return generatorNode{name: key, params: t[key]}, nil
+		}
+
+		obj := &objectNode{keys: keys, keyNodes: make([]node, len(keys)), valNodes: make([]node, len(keys))}
+		if g.preserveOrder {
+			obj.order, _ = g.keyOrder(t)
+		}
+		for z, key := range keys {
+			var err error
+			if obj.keyNodes[z], err = g.compile(key); err != nil {
+				return nil, fmt.Errorf("failed to resolve key %q: %w", key, err)
+			}
+			if literal, ok := obj.keyNodes[z].(literalNode); ok {
+				if s, ok := literal.value.(string); ok {
+					if obj.literals == nil {
+						obj.literals = make(map[string]int, len(keys))
+					}
+					obj.literals[s] = z
+				}
+			}
+			if obj.valNodes[z], err = g.compile(t[key]); err != nil {
+				return nil, fmt.Errorf("failed to resolve value of key %q: %w", key, err)
+			}
+		}
+		return obj, nil
+	case []interface{}:
+		list := make(arrayNode, len(t))
+		for z, elem := range t {
+			var err error
+			if list[z], err = g.compile(elem); err != nil {
+				return nil, fmt.Errorf("failed to resolve element %d: %w", z, err)
+			}
+		}
+		return list, nil
+	case string:
+		if !strings.HasPrefix(t, g.prefix) {
+			return literalNode{t}, nil
+		}
+		if literal, ok := g.unescape(t); ok {
+			return literalNode{literal}, nil
+		}
+		return variableNode(t), nil
+	default:
+		return literalNode{g.literalValue(template)}, nil
+	}
+}
+
+// literalValue converts the json.Number values in v to int64 when they fit
+// and to float64 otherwise, so literals are emitted as if decoded without
+// UseNumber but keep the precision of large integers. With
+// WithPreserveOrder, objects with a recorded key order become OrderedMaps.
+func (g *Generator) literalValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for z, elem := range t {
+			list[z] = g.literalValue(elem)
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(t))
+		for key, elem := range t {
+			obj[key] = g.literalValue(elem)
+		}
+		if keys, ok := g.keyOrder(t); ok && g.preserveOrder {
+			return &OrderedMap{Keys: keys, Values: obj}
+		}
+		return obj
+	default:
+		return v
+	}
+}
+
+// literalNode is a value emitted as-is.
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) resolve(g *Generator, i int) (interface{}, error) {
+	return n.value, nil
+}
+
+// variableNode is a string resolved as a variable or generator name.
+type variableNode string
+
+func (n variableNode) resolve(g *Generator, i int) (interface{}, error) {
+	generated, err := g.resolveVar(g.prefix, string(n), nil, i)
+	if isDepthError(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve variable %q: %w", string(n), err)
+	}
+	return generated, nil
+}
+
+// generatorNode is a single-key object invoking a generator.
+type generatorNode struct {
+	name   string
+	params interface{}
+}
+
+func (n generatorNode) resolve(g *Generator, i int) (interface{}, error) {
+	result, err := g.resolveVar(g.prefix, n.name, n.params, i)
+	if isDepthError(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve generator %q: %w", n.name, err)
+	}
+	return result, nil
+}
+
+// objectNode is an object whose keys and values are resolved in sorted key
+// order, so a seeded rng yields the same output. A field referred to by a
+// $ref before its turn is resolved early, on demand.
+type objectNode struct {
+	keys     []string
+	keyNodes []node
+	valNodes []node
+	order    []string       // keys in template order, if the output preserves it
+	literals map[string]int // index of each field whose key is a literal string
+}
+
+// Field states of an object being generated, used to resolve the fields a
+// $ref needs on demand and to detect reference cycles.
+const (
+	fieldPending = iota
+	fieldInProgress
+	fieldDone
+)
+
+func (n *objectNode) resolve(g *Generator, i int) (interface{}, error) {
+	generated := make(map[string]interface{}, len(n.keys))
+	// Make the object visible to $ref while its fields are generated
+	depth := len(g.scope)
+	g.scope = append(g.scope, refFrame{obj: generated, node: n, states: make([]int, len(n.keys)), i: i})
+	defer func() { g.scope = g.scope[:depth] }()
+
+	var resolvedKeys map[string]string // template key to generated key
+	if n.order != nil {
+		resolvedKeys = make(map[string]string, len(n.keys))
+		g.scope[depth].resolvedKeys = resolvedKeys
+	}
+	for z := range n.keys {
+		if g.scope[depth].states[z] == fieldDone {
+			continue
+		}
+		if err := g.ctxErr(); err != nil {
+			return nil, err
+		}
+		if err := n.resolveField(g, depth, z); err != nil {
+			return nil, err
+		}
+	}
+	if n.order == nil {
+		return generated, nil
+	}
+
+	ordered := &OrderedMap{Keys: make([]string, 0, len(generated)), Values: generated}
+	seen := make(map[string]bool, len(generated))
+	for _, key := range n.order {
+		if strKey, ok := resolvedKeys[key]; ok && !seen[strKey] {
+			seen[strKey] = true
+			ordered.Keys = append(ordered.Keys, strKey)
+		}
+	}
+	return ordered, nil
+}
+
+// resolveField resolves the z-th field of the object generated by the frame
+// g.scope[depth] and stores it in the frame's object.
+func (n *objectNode) resolveField(g *Generator, depth, z int) error {
+	key, i := n.keys[z], g.scope[depth].i
+	g.scope[depth].states[z] = fieldInProgress
+	defer func() { g.scope[depth].states[z] = fieldDone }()
+
+	resolvedKey, err := n.keyNodes[z].resolve(g, i)
+	if isDepthError(err) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve key %q: %w", key, err)
+	}
+	if resolvedKey == omitted {
+		resolvedKey = nil
+	}
+	strKey, err := objectKey(resolvedKey)
+	if err != nil {
+		return fmt.Errorf("failed to resolve key %q: %w", key, err)
+	}
+
+	g.scope[depth].key = strKey
+	resolvedVal, err := n.valNodes[z].resolve(g, i)
+	if isDepthError(err) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve value of key %q: %w", key, err)
+	}
+	if resolvedVal == omitted {
+		return nil
+	}
+	g.scope[depth].obj[strKey] = resolvedVal
+	if keys := g.scope[depth].resolvedKeys; keys != nil {
+		keys[key] = strKey
+	}
+	return nil
+}
+
+// arrayNode is a list whose elements are resolved in order. Elements that
+// resolve to an omitted value become null.
+type arrayNode []node
+
+func (n arrayNode) resolve(g *Generator, i int) (interface{}, error) {
+	generated := make([]interface{}, len(n))
+	for z, elem := range n {
+		if err := g.ctxErr(); err != nil {
+			return nil, err
+		}
+		resolved, err := elem.resolve(g, i)
+		if isDepthError(err) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve element %d: %w", z, err)
+		}
+		if resolved != omitted {
+			generated[z] = resolved
+		}
+	}
+	return generated, nil
+}
+
+// objectKey converts a resolved key to a string. Numbers, booleans, and null
+// use their JSON representation, so the int 3 becomes "3".
+func objectKey(key interface{}) (string, error) {
+	switch k := key.(type) {
+	case string:
+		return k, nil
+	case map[string]interface{}, *OrderedMap, []interface{}:
+		return "", errors.New("keys must not resolve to objects or arrays")
+	default:
+		return jsonKey(k)
+	}
+}
@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileCachesParameterTemplates(t *testing.T) {
+	g, err := New(map[string]string{"user": `{"id":"$u32"}`}, WithSource(rand.NewPCG(1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := g.Parse([]byte(`{"a":{"$arr":{"len":2,"val":{"x":"$u8","y":["$i"]}}},"b":{"$join":{"sep":"-","values":["$u8","$u8"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Compile(template); err != nil {
+		t.Fatal(err)
+	}
+
+	arr := template.(map[string]interface{})["a"].(map[string]interface{})["$arr"].(map[string]interface{})
+	join := template.(map[string]interface{})["b"].(map[string]interface{})["$join"].(map[string]interface{})
+	for name, subtree := range map[string]interface{}{
+		"$arr val":     arr["val"],
+		"nested list":  arr["val"].(map[string]interface{})["y"],
+		"$join values": join["values"],
+		"variable":     g.vars["user"],
+	} {
+		if _, ok := g.cachedNode(subtree); !ok {
+			t.Errorf("%s was not compiled ahead", name)
+		}
+	}
+	// Sublists are not mistaken for the compiled list
+	values := join["values"].([]interface{})
+	if _, ok := g.cachedNode(values[:1]); ok {
+		t.Error("a sublist of a compiled list hit the cache")
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	const template = `{"id":"$u32","name":{"$alnum":{"len":8}},"tags":{"$arr":{"len":3,"val":{"$choice":{"values":["a","b","c"]}}}},"profile":{"age":{"$int":{"min":18,"max":90}},"score":{"$float":{"min":0,"max":1}},"nested":{"ok":"$bool","i":"$i"}}}`
+	newGenerator := func(b *testing.B) (*Generator, interface{}) {
+		g, err := New(nil, WithSource(rand.NewPCG(1, 1)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		parsed, err := g.Parse([]byte(template))
+		if err != nil {
+			b.Fatal(err)
+		}
+		return g, parsed
+	}
+
+	// The template is compiled once and each record runs the plan
+	b.Run("plan", func(b *testing.B) {
+		g, parsed := newGenerator(b)
+		plan, err := g.Compile(parsed)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; b.Loop(); i++ {
+			if _, err := plan.Generate(i); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// The template is walked again for each record, as before Compile
+	b.Run("recursive", func(b *testing.B) {
+		g, parsed := newGenerator(b)
+		for i := 0; b.Loop(); i++ {
+			if _, err := g.Generate(i, parsed); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGeneratorKeyMixedWithOtherKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"top level", `{"$int":{"min":1,"max":2},"other":1}`, `generator "$int" cannot be combined with other keys in the same object`},
+		{"nested", `{"a":{"b":{"$u8":null,"c":2}}}`, `generator "$u8" cannot be combined with other keys in the same object`},
+		{"two generators", `{"$u8":null,"$bool":null}`, `generator "$bool" cannot be combined with other keys in the same object`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			template, err := g.Parse([]byte(tt.template))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The error is the same every time, whatever the map order
+			for z := 0; z < 10; z++ {
+				if _, err := g.Compile(template); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("Compile: got error %v, want one containing %q", err, tt.want)
+				}
+				if err := g.Validate(template); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("Validate: got error %v, want one containing %q", err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSingleGeneratorKey(t *testing.T) {
+	records := generateRecords(t, `{"a":{"$int":{"min":3,"max":3}},"b":{"c":{"$const":"x"}}}`, 1, 1)
+	want := map[string]interface{}{"a": 3, "b": map[string]interface{}{"c": "x"}}
+	if !reflect.DeepEqual(records[0], want) {
+		t.Errorf("got %v, want %v", records[0], want)
+	}
+}
+
+func TestEscapedPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     interface{}
+	}{
+		{"arbitrary word", `{"price":"$$5.00"}`, map[string]interface{}{"price": "$5.00"}},
+		{"generator name", `{"a":"$$int"}`, map[string]interface{}{"a": "$int"}},
+		{"doubled twice", `{"a":"$$$$x"}`, map[string]interface{}{"a": "$$$x"}},
+		{"key", `{"$$key":"$$value"}`, map[string]interface{}{"$key": "$value"}},
+		{"in a list", `{"a":["$$u8","$$"]}`, map[string]interface{}{"a": []interface{}{"$u8", "$"}}},
+		{"generator object key", `{"a":{"$$int":{"min":1}}}`, map[string]interface{}{"a": map[string]interface{}{"$int": map[string]interface{}{"min": int64(1)}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generateRecords(t, tt.template, 1, 1)[0]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+	// With another prefix, the escape is that prefix doubled
+	got := generateRecords(t, `{"a":"@@u8","b":"$u8"}`, 1, 1, WithPrefix("@"))[0]
+	if want := map[string]interface{}{"a": "@u8", "b": "$u8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// dateLayouts are the layouts accepted when a generator reads a date value.
+var dateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+func parseDate(value interface{}) (time.Time, error) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("expected a date string but got %T", value)
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a date", s)
+}
+
+// randomInstant returns a random instant between the "min" and "max" fields
+// of params, to the second. Missing bounds default to the past year up to now.
+func (g *Generator) randomInstant(name string, params interface{}) (time.Time, error) {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok && params != nil {
+		return time.Time{}, fmt.Errorf("%s accepts an optional {min, max} object", name)
+	}
+
+	max := time.Now().UTC()
+	min := max.AddDate(-1, 0, 0)
+	if v, exists := paramsMap["min"]; exists {
+		t, err := parseDate(v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid min for %s: %w", name, err)
+		}
+		min = t
+	}
+	if v, exists := paramsMap["max"]; exists {
+		t, err := parseDate(v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid max for %s: %w", name, err)
+		}
+		max = t
+	}
+	if max.Before(min) {
+		return time.Time{}, fmt.Errorf("%s: max (%s) must not be before min (%s)", name, max.Format(time.RFC3339), min.Format(time.RFC3339))
+	}
+
+	seconds := min.Unix() + g.rng.Int64N(max.Unix()-min.Unix()+1)
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// formatInstant formats t with the optional "format" field of params, or with
+// defaultLayout when it is absent.
+func formatInstant(name string, t time.Time, params interface{}, defaultLayout string) (string, error) {
+	paramsMap, _ := params.(map[string]interface{})
+	layout := defaultLayout
+	if v, exists := paramsMap["format"]; exists {
+		if layout, _ = v.(string); layout == "" {
+			return "", fmt.Errorf("invalid format for %s", name)
+		}
+	}
+	return t.Format(layout), nil
+}
+
+// birthdateParams resolves the "birthdate" and optional "today" fields shared
+// by $age and $zodiac.
+func (g *Generator) birthdateParams(name string, params interface{}, i int) (time.Time, time.Time, error) {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok {
+		return time.Time{}, time.Time{}, fmt.Errorf("%s requires a {birthdate} object", name)
+	}
+	raw, exists := paramsMap["birthdate"]
+	if !exists {
+		return time.Time{}, time.Time{}, fmt.Errorf("missing birthdate for %s", name)
+	}
+	resolved, err := g.Generate(i, raw)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to resolve birthdate for %s: %w", name, err)
+	}
+	birthdate, err := parseDate(resolved)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid birthdate for %s: %w", name, err)
+	}
+
+	today := time.Now()
+	if rawToday, exists := paramsMap["today"]; exists {
+		today, err = parseDate(rawToday)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid today for %s: %w", name, err)
+		}
+	}
+	return birthdate, today, nil
+}
+
+// ageAt returns the number of whole years between birthdate and today.
+func ageAt(birthdate, today time.Time) (int, error) {
+	if today.Before(birthdate) {
+		return 0, errors.New("birthdate is in the future")
+	}
+	age := today.Year() - birthdate.Year()
+	if today.Month() < birthdate.Month() ||
+		(today.Month() == birthdate.Month() && today.Day() < birthdate.Day()) {
+		age--
+	}
+	return age, nil
+}
+
+// zodiacSigns lists each sign with the first day it covers, in calendar order.
+var zodiacSigns = []struct {
+	month time.Month
+	day   int
+	sign  string
+}{
+	{time.January, 20, "Aquarius"},
+	{time.February, 19, "Pisces"},
+	{time.March, 21, "Aries"},
+	{time.April, 20, "Taurus"},
+	{time.May, 21, "Gemini"},
+	{time.June, 21, "Cancer"},
+	{time.July, 23, "Leo"},
+	{time.August, 23, "Virgo"},
+	{time.September, 23, "Libra"},
+	{time.October, 23, "Scorpio"},
+	{time.November, 22, "Sagittarius"},
+	{time.December, 22, "Capricorn"},
+}
+
+func zodiacSign(date time.Time) string {
+	sign := "Capricorn" // covers early January
+	for _, z := range zodiacSigns {
+		if date.Month() > z.month || (date.Month() == z.month && date.Day() >= z.day) {
+			sign = z.sign
+		}
+	}
+	return sign
+}
+
+// timestamp returns a random instant as an integer count of the "unit"
+// field of params ("s", "ms", or "ns", seconds by default) since the Unix
+// epoch. Like $datetime, the instant has whole-second resolution.
+func (g *Generator) timestamp(params interface{}) (int64, error) {
+	t, err := g.randomInstant("$timestamp", params)
+	if err != nil {
+		return 0, err
+	}
+	paramsMap, _ := params.(map[string]interface{})
+	unit := "s"
+	if v, exists := paramsMap["unit"]; exists {
+		if unit, _ = v.(string); unit == "" {
+			return 0, errors.New("invalid unit for $timestamp")
+		}
+	}
+	switch unit {
+	case "s":
+		return t.Unix(), nil
+	case "ms":
+		return t.UnixMilli(), nil
+	case "ns":
+		return t.UnixNano(), nil
+	default:
+		return 0, fmt.Errorf(`$timestamp: unknown unit %q (supported: s, ms, ns)`, unit)
+	}
+}
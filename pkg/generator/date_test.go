@@ -0,0 +1,76 @@
+package generator
+
+import "testing"
+
+func TestAge(t *testing.T) {
+	tests := []struct {
+		birthdate, today string
+		want             int
+	}{
+		{"1990-06-15", "2020-06-14", 29},
+		{"1990-06-15", "2020-06-15", 30},
+		{"1990-06-15", "2020-12-31", 30},
+		{"2000-02-29", "2001-02-28", 0},
+		{"2000-02-29", "2001-03-01", 1},
+		{"2020-01-01", "2020-01-01", 0},
+	}
+	for _, tt := range tests {
+		template := `{"age":{"$age":{"birthdate":"` + tt.birthdate + `","today":"` + tt.today + `"}}}`
+		records := generateRecords(t, template, 1, 1)
+		if got := field(records, "age")[0]; got != tt.want {
+			t.Errorf("age of %s on %s: got %v, want %d", tt.birthdate, tt.today, got, tt.want)
+		}
+	}
+}
+
+func TestZodiac(t *testing.T) {
+	tests := []struct {
+		birthdate, want string
+	}{
+		{"2000-01-19", "Capricorn"},
+		{"2000-01-20", "Aquarius"},
+		{"2000-03-20", "Pisces"},
+		{"2000-03-21", "Aries"},
+		{"2000-07-22", "Cancer"},
+		{"2000-07-23", "Leo"},
+		{"2000-12-21", "Sagittarius"},
+		{"2000-12-22", "Capricorn"},
+	}
+	for _, tt := range tests {
+		records := generateRecords(t, `{"sign":{"$zodiac":{"birthdate":"`+tt.birthdate+`"}}}`, 1, 1)
+		if got := field(records, "sign")[0]; got != tt.want {
+			t.Errorf("sign of %s: got %v, want %s", tt.birthdate, got, tt.want)
+		}
+	}
+}
+
+func TestAgeFromGeneratedBirthdate(t *testing.T) {
+	template := `{"dob":{"$date":{"min":"1950-01-01","max":"2000-12-31"}},"age":{"$age":{"birthdate":"$ref:dob","today":"2025-01-01"}},"sign":{"$zodiac":{"birthdate":{"$ref":"dob"}}}}`
+	for _, record := range generateRecords(t, template, 50, 1) {
+		r := record.(map[string]interface{})
+		if age := r["age"].(int); age < 24 || age > 75 {
+			t.Errorf("age %d of birthdate %v is out of range", age, r["dob"])
+		}
+		if sign, _ := r["sign"].(string); sign == "" {
+			t.Errorf("no sign for birthdate %v", r["dob"])
+		}
+	}
+	assertDeterministic(t, template, 10)
+}
+
+func TestAgeErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{"a":{"$age":"1990-01-01"}}`, "$age requires a {birthdate} object"},
+		{`{"a":{"$age":{"birthdate":"soon"}}}`, "invalid birthdate for $age"},
+		{`{"a":{"$age":{"birthdate":"1990-01-01","today":"never"}}}`, "invalid today for $age"},
+		{`{"a":{"$age":{"birthdate":"2030-01-01","today":"2020-01-01"}}}`, "$age: birthdate is in the future"},
+		{`{"a":{"$zodiac":{"birthdate":42}}}`, "invalid birthdate for $zodiac"},
+		{`{"a":{"$age":{"birthdate":"$ref:missing"}}}`, `$ref: field "missing" has not been generated`},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, tt.template, tt.want)
+	}
+}
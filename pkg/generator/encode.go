@@ -0,0 +1,360 @@
+package generator
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format for generated records.
+type Format string
+
+const (
+	FormatJSONL Format = "jsonl" // one JSON record per line
+	FormatArray Format = "array" // a single JSON array of records
+	FormatCSV   Format = "csv"   // flat object records as CSV rows
+	FormatYAML  Format = "yaml"  // one YAML document per record
+	FormatTOML  Format = "toml"  // one [[record]] table per record
+)
+
+// Encoder turns generated records into output bytes.
+type Encoder interface {
+	// Header returns the bytes written before the first record.
+	Header() string
+	// Encode returns the bytes for the n-th record (counting from 0),
+	// including any separator needed before it.
+	Encode(n int, v interface{}) (string, error)
+	// Footer returns the bytes written after the last of n records.
+	Footer(n int) string
+}
+
+// NewEncoder returns the encoder for format. A non-empty indent pretty-prints
+// JSON output with that indentation, always as an array.
+func NewEncoder(format Format, indent string) (Encoder, error) {
+	switch format {
+	case FormatJSONL, FormatArray:
+		enc := &jsonEncoder{array: format == FormatArray}
+		if indent != "" {
+			enc.array = true
+			enc.indent = indent
+		}
+		return enc, nil
+	case FormatCSV, FormatYAML, FormatTOML:
+		if indent != "" {
+			return nil, errors.New("pretty output is only supported for JSON formats")
+		}
+		switch format {
+		case FormatCSV:
+			return &csvEncoder{}, nil
+		case FormatYAML:
+			return yamlEncoder{}, nil
+		default:
+			return tomlEncoder{}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q (supported: jsonl, array, csv, yaml, toml)", format)
+	}
+}
+
+// jsonEncoder writes records as JSONL, or in array mode streams them as the
+// elements of a single JSON array.
+type jsonEncoder struct {
+	array  bool
+	indent string // indentation for pretty output, empty for compact output
+}
+
+func (e *jsonEncoder) Header() string {
+	if e.array {
+		return "[\n"
+	}
+	return ""
+}
+
+func (e *jsonEncoder) Encode(n int, v interface{}) (string, error) {
+	var data []byte
+	var err error
+	if e.indent != "" {
+		data, err = json.MarshalIndent(v, e.indent, e.indent)
+	} else {
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !e.array {
+		return string(data) + "\n", nil
+	}
+	// Elements are indented one level, by two spaces in compact output
+	lead := e.indent
+	if lead == "" {
+		lead = "  "
+	}
+	if n == 0 {
+		return lead + string(data), nil
+	}
+	return ",\n" + lead + string(data), nil
+}
+
+func (e *jsonEncoder) Footer(n int) string {
+	if !e.array {
+		return ""
+	}
+	if n == 0 {
+		return "]\n"
+	}
+	return "\n]\n"
+}
+
+// csvEncoder writes flat object records as CSV rows. The columns are the
+// keys of the first record, sorted unless the record preserves template
+// order, written as a header row before it.
+type csvEncoder struct {
+	columns []string
+}
+
+func (e *csvEncoder) Header() string {
+	return ""
+}
+
+func (e *csvEncoder) Encode(n int, v interface{}) (string, error) {
+	var record map[string]interface{}
+	var keys []string // column order of the first record
+	switch r := v.(type) {
+	case map[string]interface{}:
+		record = r
+		for key := range record {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	case *OrderedMap:
+		record, keys = r.Values, r.Keys
+	default:
+		return "", fmt.Errorf("csv output requires object records, got %T", v)
+	}
+
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	if n == 0 {
+		e.columns = keys
+		if err := w.Write(e.columns); err != nil {
+			return "", err
+		}
+	}
+	if len(record) != len(e.columns) {
+		return "", fmt.Errorf("csv record %d has different keys than the header", n)
+	}
+
+	row := make([]string, len(e.columns))
+	for z, column := range e.columns {
+		value, exists := record[column]
+		if !exists {
+			return "", fmt.Errorf("csv record %d is missing column %q", n, column)
+		}
+		cell, err := csvCell(value)
+		if err != nil {
+			return "", fmt.Errorf("csv column %q: %w", column, err)
+		}
+		row[z] = cell
+	}
+	if err := w.Write(row); err != nil {
+		return "", err
+	}
+	w.Flush()
+	return sb.String(), w.Error()
+}
+
+func (e *csvEncoder) Footer(n int) string {
+	return ""
+}
+
+// csvCell formats a scalar value for a CSV cell. Nested objects and arrays
+// cannot be represented and return an error.
+func csvCell(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		if len(data) > 0 && (data[0] == '{' || data[0] == '[') {
+			return "", errors.New("nested objects and arrays are not supported")
+		}
+		return string(data), nil
+	}
+}
+
+// yamlEncoder writes each record as a YAML document, with "---" markers
+// between documents.
+type yamlEncoder struct{}
+
+func (e yamlEncoder) Header() string {
+	return ""
+}
+
+func (e yamlEncoder) Encode(n int, v interface{}) (string, error) {
+	// Going through JSON keeps the key order and number formatting of the
+	// JSON output; YAML is a superset of JSON, so the result parses as YAML
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return "", err
+	}
+	resetYAMLStyle(&doc)
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return string(out), nil
+	}
+	return "---\n" + string(out), nil
+}
+
+func (e yamlEncoder) Footer(n int) string {
+	return ""
+}
+
+// resetYAMLStyle clears the flow and quoting styles taken from the JSON
+// input, so the node is written in block style.
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// WriteOptions configures Plan.WriteRecords. The zero value writes every
+// record compactly to a single output and stops at the first error.
+type WriteOptions struct {
+	// Indent pretty-prints JSON output with this indentation.
+	Indent string
+	// MaxBytes stops before the record that would make the output, including
+	// its footer, exceed this many bytes. Zero means no limit.
+	MaxBytes int64
+	// Split starts a new output, obtained from NextOutput, after every Split
+	// records. Each output is complete, with its own header and footer.
+	Split      int
+	NextOutput func() (io.Writer, error)
+	// Generate generates the i-th record instead of the plan, for example to
+	// collect records generated by several plans in parallel.
+	Generate func(i int) (interface{}, error)
+	// Check is called with each record before it is written; an error is
+	// handled like an error generating the record.
+	Check func(i int, v interface{}) error
+	// Skip reports whether to drop the i-th record, which failed with err,
+	// and carry on instead of stopping.
+	Skip func(i int, err error) bool
+	// Written is called after each record is written.
+	Written func(v interface{}) error
+}
+
+// WriteResult summarizes a call to Plan.WriteRecords.
+type WriteResult struct {
+	Bytes   int64 // bytes written, across all outputs
+	Records int   // records written
+	Skipped int   // records dropped by WriteOptions.Skip
+}
+
+// WriteRecords writes records 0 to count-1 of the plan to w in format, or
+// records without end if count is negative, and returns what it wrote.
+//
+// Once ctx is done, the output is finished with its footer and the context's
+// error is returned. Any other error stops the output where it is. The method
+// is not named WriteTo since that name is reserved for io.WriterTo, and it
+// belongs to a Plan since a Generator has no template of its own.
+func (p *Plan) WriteRecords(ctx context.Context, w io.Writer, count int, format Format, opts WriteOptions) (WriteResult, error) {
+	var res WriteResult
+	enc, err := NewEncoder(format, opts.Indent)
+	if err != nil {
+		return res, err
+	}
+	if opts.Split > 0 && opts.NextOutput == nil {
+		return res, errors.New("splitting the output requires NextOutput")
+	}
+	generate := opts.Generate
+	if generate == nil {
+		generate = func(i int) (interface{}, error) { return p.GenerateContext(ctx, i) }
+	}
+	write := func(chunk string) error {
+		n, err := io.WriteString(w, chunk)
+		res.Bytes += int64(n)
+		if err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		return nil
+	}
+
+	if err := write(enc.Header()); err != nil {
+		return res, err
+	}
+	n := 0 // records in the current output
+	for i := 0; count < 0 || i < count; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		result, err := generate(i)
+		if err == nil && opts.Check != nil {
+			err = opts.Check(i, result)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			if opts.Skip != nil && opts.Skip(i, err) {
+				res.Skipped++
+				continue
+			}
+			return res, err
+		}
+
+		if opts.Split > 0 && n == opts.Split {
+			if err := write(enc.Footer(n)); err != nil {
+				return res, err
+			}
+			if w, err = opts.NextOutput(); err != nil {
+				return res, err
+			}
+			if err := write(enc.Header()); err != nil {
+				return res, err
+			}
+			n = 0
+		}
+
+		chunk, err := enc.Encode(n, result)
+		if err != nil {
+			return res, fmt.Errorf("failed to encode record %d: %w", i, err)
+		}
+		if opts.MaxBytes > 0 && res.Bytes+int64(len(chunk)+len(enc.Footer(n+1))) > opts.MaxBytes {
+			break
+		}
+		if err := write(chunk); err != nil {
+			return res, err
+		}
+		res.Records++
+		n++
+		if opts.Written != nil {
+			if err := opts.Written(result); err != nil {
+				return res, err
+			}
+		}
+	}
+	if err := write(enc.Footer(n)); err != nil {
+		return res, err
+	}
+	return res, ctx.Err()
+}
@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// compilePlan compiles template with a seeded generator.
+func compilePlan(t *testing.T, template string) *Plan {
+	t.Helper()
+	g, err := New(nil, WithSource(rand.NewPCG(1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := g.Parse([]byte(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := g.Compile(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+func TestWriteRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		opts    WriteOptions
+		want    string
+		records int
+	}{
+		{"jsonl", FormatJSONL, WriteOptions{}, "{\"i\":0}\n{\"i\":1}\n{\"i\":2}\n", 3},
+		{"array", FormatArray, WriteOptions{}, "[\n  {\"i\":0},\n  {\"i\":1},\n  {\"i\":2}\n]\n", 3},
+		{"indent", FormatJSONL, WriteOptions{Indent: " "}, "[\n {\n  \"i\": 0\n },\n {\n  \"i\": 1\n },\n {\n  \"i\": 2\n }\n]\n", 3},
+		{"max bytes", FormatJSONL, WriteOptions{MaxBytes: 17}, "{\"i\":0}\n{\"i\":1}\n", 2},
+		{"max bytes with footer", FormatArray, WriteOptions{MaxBytes: 25}, "[\n  {\"i\":0},\n  {\"i\":1}\n]\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			res, err := compilePlan(t, `{"i":"$i"}`).WriteRecords(context.Background(), &buf, 3, tt.format, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+			if res.Records != tt.records || res.Bytes != int64(buf.Len()) {
+				t.Errorf("got %+v, want %d records in %d bytes", res, tt.records, buf.Len())
+			}
+		})
+	}
+}
+
+func TestWriteRecordsSplit(t *testing.T) {
+	outputs := []*bytes.Buffer{{}}
+	opts := WriteOptions{Split: 2, NextOutput: func() (io.Writer, error) {
+		outputs = append(outputs, &bytes.Buffer{})
+		return outputs[len(outputs)-1], nil
+	}}
+	if _, err := compilePlan(t, `{"i":"$i"}`).WriteRecords(context.Background(), outputs[0], 5, FormatArray, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"[\n  {\"i\":0},\n  {\"i\":1}\n]\n",
+		"[\n  {\"i\":2},\n  {\"i\":3}\n]\n",
+		"[\n  {\"i\":4}\n]\n",
+	}
+	if len(outputs) != len(want) {
+		t.Fatalf("got %d outputs, want %d", len(outputs), len(want))
+	}
+	for z, out := range outputs {
+		if out.String() != want[z] {
+			t.Errorf("output %d is %q, want %q", z, out.String(), want[z])
+		}
+	}
+}
+
+func TestWriteRecordsErrors(t *testing.T) {
+	plan := compilePlan(t, `{"i":"$i"}`)
+	errOdd := errors.New("odd record")
+	checkOdd := func(i int, v interface{}) error {
+		if i%2 == 1 {
+			return errOdd
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	res, err := plan.WriteRecords(context.Background(), &buf, 5, FormatJSONL, WriteOptions{Check: checkOdd})
+	if !errors.Is(err, errOdd) || res.Records != 1 {
+		t.Errorf("got %+v, %v; want 1 record and the check's error", res, err)
+	}
+
+	buf.Reset()
+	skip := func(i int, err error) bool { return errors.Is(err, errOdd) }
+	res, err = plan.WriteRecords(context.Background(), &buf, 5, FormatJSONL, WriteOptions{Check: checkOdd, Skip: skip})
+	if err != nil || res.Records != 3 || res.Skipped != 2 {
+		t.Errorf("got %+v, %v; want 3 records and 2 skipped", res, err)
+	}
+	if want := "{\"i\":0}\n{\"i\":2}\n{\"i\":4}\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteRecordsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var buf bytes.Buffer
+	opts := WriteOptions{Written: func(v interface{}) error {
+		cancel()
+		return nil
+	}}
+	res, err := compilePlan(t, `{"i":"$i"}`).WriteRecords(ctx, &buf, -1, FormatArray, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	if res.Records != 1 || !strings.HasSuffix(buf.String(), "\n]\n") {
+		t.Errorf("got %d records in %q, want 1 record in a closed array", res.Records, buf.String())
+	}
+}
@@ -0,0 +1,139 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// expr resolves the args of a {"op": ..., "args": [...]} object and folds
+// them left to right with op. Integer operands give an integer result,
+// except for "/", which always divides as floats, and an integer result
+// that overflows int is an error rather than wrapping around.
+func (g *Generator) expr(params interface{}, i int) (interface{}, error) {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("$expr requires an {op, args} object")
+	}
+	op, ok := paramsMap["op"].(string)
+	if !ok {
+		return nil, errors.New("op for $expr must be a string")
+	}
+	switch op {
+	case "+", "-", "*", "/", "%":
+	default:
+		return nil, fmt.Errorf("$expr: unknown op %q (supported: + - * / %%)", op)
+	}
+	args, ok := paramsMap["args"].([]interface{})
+	if !ok || len(args) < 2 {
+		return nil, errors.New("args for $expr must be a list of at least two values")
+	}
+
+	operands := make([]interface{}, len(args))
+	integers := op != "/"
+	for z, arg := range args {
+		resolved, err := g.Generate(i, arg)
+		if err != nil {
+			return nil, err
+		}
+		if _, isInt := exprInt(resolved); !isInt {
+			if _, ok := convertToFloat(resolved); !ok {
+				return nil, fmt.Errorf("$expr: operand %d is not a number: %v", z, resolved)
+			}
+			integers = false
+		}
+		operands[z] = resolved
+	}
+
+	if integers {
+		acc, _ := exprInt(operands[0])
+		for _, operand := range operands[1:] {
+			n, _ := exprInt(operand)
+			overflow := false
+			switch op {
+			case "+":
+				acc, overflow = addInt(acc, n)
+			case "-":
+				acc, overflow = subInt(acc, n)
+			case "*":
+				acc, overflow = mulInt(acc, n)
+			case "%":
+				if n == 0 {
+					return nil, errors.New("$expr: division by zero")
+				}
+				acc %= n
+			}
+			if overflow {
+				return nil, errors.New("$expr: integer overflow")
+			}
+		}
+		return acc, nil
+	}
+
+	acc, _ := exprFloat(operands[0])
+	for _, operand := range operands[1:] {
+		f, _ := exprFloat(operand)
+		switch op {
+		case "+":
+			acc += f
+		case "-":
+			acc -= f
+		case "*":
+			acc *= f
+		case "/", "%":
+			if f == 0 {
+				return nil, errors.New("$expr: division by zero")
+			}
+			if op == "/" {
+				acc /= f
+			} else {
+				acc = math.Mod(acc, f)
+			}
+		}
+	}
+	return acc, nil
+}
+
+// exprInt returns v as an int if it is an integer value. Floats are not
+// integers even when whole, so 2.0 keeps float arithmetic.
+func exprInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return 0, false
+	case json.Number:
+		if _, err := n.Int64(); err != nil {
+			return 0, false
+		}
+	case string, bool, nil:
+		return 0, false
+	}
+	n, err := convertToInt(v)
+	return n, err == nil
+}
+
+func exprFloat(v interface{}) (float64, bool) {
+	if n, ok := exprInt(v); ok {
+		return float64(n), true
+	}
+	return convertToFloat(v)
+}
+
+// addInt returns a+b and whether the sum overflowed.
+func addInt(a, b int) (int, bool) {
+	sum := a + b
+	return sum, (sum > a) != (b > 0)
+}
+
+// subInt returns a-b and whether the difference overflowed.
+func subInt(a, b int) (int, bool) {
+	diff := a - b
+	return diff, (diff < a) != (b > 0)
+}
+
+// mulInt returns a*b and whether the product overflowed.
+func mulInt(a, b int) (int, bool) {
+	product := a * b
+	overflow := a != 0 && (product/a != b || (a == -1 && b == math.MinInt))
+	return product, overflow
+}
@@ -0,0 +1,54 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		want   interface{}
+	}{
+		{"add", `{"op":"+","args":[1,2,3]}`, 6},
+		{"subtract", `{"op":"-","args":[10,3,2]}`, 5},
+		{"multiply", `{"op":"*","args":[2,3,4]}`, 24},
+		{"modulo", `{"op":"%","args":[17,5]}`, 2},
+		{"divide", `{"op":"/","args":[7,2]}`, 3.5},
+		{"float operand", `{"op":"+","args":[1,0.5]}`, 1.5},
+		{"generated operand", `{"op":"*","args":[{"$int":{"min":3,"max":3}},2]}`, 6},
+		{"largest int", `{"op":"+","args":[9223372036854775806,1]}`, 9223372036854775807},
+		{"smallest int", `{"op":"-","args":[-9223372036854775807,1]}`, -9223372036854775808},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, v := range field(generateRecords(t, `{"e":{"$expr":`+tt.params+`}}`, 2, 1), "e") {
+				if !reflect.DeepEqual(v, tt.want) {
+					t.Errorf("got %#v, want %#v", v, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"op":"/","args":[1,0]}`, "$expr: division by zero"},
+		{`{"op":"%","args":[1,0]}`, "$expr: division by zero"},
+		{`{"op":"*","args":[9223372036854775807,2]}`, "$expr: integer overflow"},
+		{`{"op":"*","args":[-9223372036854775808,-1]}`, "$expr: integer overflow"},
+		{`{"op":"+","args":[9223372036854775807,1]}`, "$expr: integer overflow"},
+		{`{"op":"-","args":[-9223372036854775808,1]}`, "$expr: integer overflow"},
+		{`{"op":"-","args":[0,-9223372036854775808]}`, "$expr: integer overflow"},
+		{`{"op":"^","args":[1,2]}`, `$expr: unknown op "^"`},
+		{`{"op":"+","args":[1]}`, "args for $expr must be a list of at least two values"},
+		{`{"op":"+","args":[1,"a"]}`, "$expr: operand 1 is not a number: a"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"e":{"$expr":`+tt.params+`}}`, tt.want)
+	}
+}
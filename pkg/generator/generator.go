@@ -0,0 +1,1835 @@
+// Package generator resolves rjg JSON templates into random JSON values.
+package generator
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generator resolves JSON templates into generated values.
+type Generator struct {
+	prefix         string
+	predefinedVars map[string]func() interface{}
+	vars           map[string]interface{}
+	files          map[string][]string // lines of files read by generators, keyed by path
+	pools          map[string][]interface{}
+	count          int // total number of records in the run
+	rng            *rand.Rand
+	scope          []refFrame                 // objects being generated, outermost first
+	seqs           map[string]int             // next value of each $seq counter, keyed by name
+	incrs          map[string]*incrState      // state of each $incr, keyed by field path
+	uniques        map[string]map[string]bool // values emitted by each $unique, keyed by field path
+	depth          int                        // user-defined variables currently being expanded
+	maxDepth       int
+	ctx            context.Context          // context of the GenerateContext call in progress, if any
+	keyOrders      map[uintptr]orderedKeys  // key order of the objects decoded by Parse
+	preserveOrder  bool                     // generate objects as OrderedMaps
+	locals         map[string]interface{}   // variables bound by the $map calls in progress
+	compiled       map[uintptr]compiledNode // template subtrees compiled ahead, keyed by address
+	caching        bool                     // store subtrees in compiled as they are compiled
+}
+
+// maxRetries bounds how many times a generator re-draws a value that has to
+// satisfy a constraint before giving up.
+const maxRetries = 100
+
+// defaultMaxDepth bounds how deeply user-defined variables may expand into
+// each other, so that a cyclic definition fails instead of overflowing the
+// stack.
+const defaultMaxDepth = 100
+
+// depthError reports a user-defined variable that expanded past the maximum
+// depth. It is returned unwrapped through the nested expansions so that the
+// message does not repeat once per level.
+type depthError struct {
+	name  string
+	limit int
+}
+
+func (e *depthError) Error() string {
+	return fmt.Sprintf("maximum recursion depth exceeded (%d) while resolving variable %q", e.limit, e.name)
+}
+
+func isDepthError(err error) bool {
+	var de *depthError
+	return errors.As(err, &de)
+}
+
+var prefixed = map[string]bool{
+	"int":         true,
+	"float":       true,
+	"str":         true,
+	"arr":         true,
+	"obj":         true,
+	"oneof":       true,
+	"option":      true,
+	"i":           true,
+	"u8":          true,
+	"u16":         true,
+	"u32":         true,
+	"i8":          true,
+	"i16":         true,
+	"i32":         true,
+	"i64":         true,
+	"digit":       true,
+	"bool":        true,
+	"alpha":       true,
+	"person":      true,
+	"age":         true,
+	"zodiac":      true,
+	"measurement": true,
+	"subset":      true,
+	"line":        true,
+	"register":    true,
+	"edge":        true,
+	"padIndex":    true,
+	"except":      true,
+	"split":       true,
+	"count":       true,
+	"freqStr":     true,
+	"uuid":        true,
+	"date":        true,
+	"datetime":    true,
+	"regex":       true,
+	"ref":         true,
+	"seq":         true,
+	"email":       true,
+	"ipv4":        true,
+	"ipv6":        true,
+	"lower":       true,
+	"upper":       true,
+	"title":       true,
+	"format":      true,
+	"join":        true,
+	"unique":      true,
+	"null":        true,
+	"nullable":    true,
+	"const":       true,
+	"hex":         true,
+	"bytes":       true,
+	"alnum":       true,
+	"choice":      true,
+	"repeat":      true,
+	"expr":        true,
+	"if":          true,
+	"firstname":   true,
+	"lastname":    true,
+	"name":        true,
+	"lorem":       true,
+	"url":         true,
+	"color":       true,
+	"hexcolor":    true,
+	"creditcard":  true,
+	"semver":      true,
+	"timestamp":   true,
+	"latlng":      true,
+	"phone":       true,
+	"map":         true,
+	"pick":        true,
+	"incr":        true,
+	"seeded":      true,
+	"password":    true,
+	"shuffle":     true,
+	"company":     true,
+	"jobtitle":    true,
+	"department":  true,
+	"useragent":   true,
+}
+
+func isPredefinedVar(value string) bool {
+	return prefixed[value]
+}
+
+// generatorName returns the generator that s names, if s is the prefix
+// followed by a predefined generator.
+func (g *Generator) generatorName(s string) (string, bool) {
+	if !strings.HasPrefix(s, g.prefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(s, g.prefix)
+	return name, isPredefinedVar(name)
+}
+
+// unescape returns s without its first prefix if s starts with the prefix
+// written twice, which escapes a literal string such as "$$5.00".
+func (g *Generator) unescape(s string) (string, bool) {
+	if !strings.HasPrefix(s, g.prefix+g.prefix) {
+		return s, false
+	}
+	return strings.TrimPrefix(s, g.prefix), true
+}
+
+// ParseTemplate decodes a JSON template. Numbers are kept as json.Number so
+// that large integers do not lose precision on the way to a generator.
+func ParseTemplate(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var template interface{}
+	if err := dec.Decode(&template); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return template, nil
+}
+
+// Option configures a Generator created with New.
+type Option func(*Generator)
+
+// WithSource makes the Generator draw its randomness from src, which makes
+// the output reproducible for a seeded source.
+func WithSource(src rand.Source) Option {
+	return func(g *Generator) {
+		g.rng = rand.New(src)
+	}
+}
+
+// WithCount tells the Generator how many records the run produces, for
+// generators such as $split and $count that depend on it.
+func WithCount(count int) Option {
+	return func(g *Generator) {
+		g.count = count
+	}
+}
+
+// WithPrefix sets the prefix that marks generators and variables in
+// templates, "$" by default.
+func WithPrefix(prefix string) Option {
+	return func(g *Generator) {
+		g.prefix = prefix
+	}
+}
+
+// WithMaxDepth sets how deeply user-defined variables may expand into each
+// other before resolution fails, 100 by default.
+func WithMaxDepth(depth int) Option {
+	return func(g *Generator) {
+		g.maxDepth = depth
+	}
+}
+
+// WithVars adds already-parsed template fragments as user-defined variables.
+// Variables passed to New take precedence over these on conflict.
+func WithVars(vars map[string]interface{}) Option {
+	return func(g *Generator) {
+		for k, v := range vars {
+			if _, exists := g.vars[k]; !exists {
+				g.vars[k] = v
+			}
+		}
+	}
+}
+
+// New creates a Generator with the given user-defined variables. Each value
+// is parsed as a JSON template fragment; values that are not valid JSON are
+// stored as plain strings. Without WithSource, randomness comes from a
+// source seeded by the operating system.
+func New(vars map[string]string, opts ...Option) (*Generator, error) {
+	g := &Generator{
+		prefix:         "$",
+		predefinedVars: nil,
+		vars:           make(map[string]interface{}),
+		files:          make(map[string][]string),
+		pools:          make(map[string][]interface{}),
+		seqs:           make(map[string]int),
+		incrs:          make(map[string]*incrState),
+		uniques:        make(map[string]map[string]bool),
+		maxDepth:       defaultMaxDepth,
+		locals:         make(map[string]interface{}),
+		keyOrders:      make(map[uintptr]orderedKeys),
+		compiled:       make(map[uintptr]compiledNode),
+	}
+	for k, v := range vars {
+		if k == "" {
+			return nil, errors.New("variable names must not be empty")
+		}
+		parsedValue, err := g.Parse([]byte(v))
+		if err != nil {
+			parsedValue = v
+		}
+		g.vars[k] = parsedValue
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.prefix == "" {
+		return nil, errors.New("prefix must not be empty")
+	}
+	if g.maxDepth < 1 {
+		return nil, errors.New("maximum depth must be at least 1")
+	}
+	if g.rng == nil {
+		var seed [32]byte
+		cryptorand.Read(seed[:])
+		g.rng = rand.New(rand.NewChaCha8(seed))
+	}
+	// Variables are templates too, expanded wherever they are used
+	g.caching = true
+	for _, v := range g.vars {
+		g.precompile(v)
+	}
+	g.caching = false
+
+	return g, nil
+}
+
+// incrState is the counter of an $incr and the last record it counted.
+type incrState struct {
+	value  int
+	record int
+}
+
+// omittedValue is the type of omitted.
+type omittedValue struct{}
+
+// omitted is produced by generators such as $option to drop the enclosing
+// object key. Anywhere else it resolves to null.
+var omitted = omittedValue{}
+
+// Generate resolves template for the i-th record.
+//
+// An object whose only key is a generator (e.g. {"$int": {...}}) is a
+// generator invocation and resolves to the generated value. A generator key
+// mixed with other keys is an error. A string starting with the prefix
+// written twice is a literal: "$$5.00" resolves to "$5.00".
+func (g *Generator) Generate(i int, template interface{}) (interface{}, error) {
+	result, err := g.generate(i, template)
+	if result == omitted {
+		return nil, err
+	}
+	return result, err
+}
+
+// GenerateContext is like Generate but stops with the context's error once
+// ctx is done.
+func (g *Generator) GenerateContext(ctx context.Context, i int, template interface{}) (interface{}, error) {
+	g.ctx = ctx
+	defer func() { g.ctx = nil }()
+	return g.Generate(i, template)
+}
+
+// ctxErr returns the error of the context passed to GenerateContext, if it
+// is done.
+func (g *Generator) ctxErr() error {
+	if g.ctx == nil {
+		return nil
+	}
+	return g.ctx.Err()
+}
+
+// generate is Generate without resolving omitted values, so that they can
+// pass through to the enclosing object. Subtrees of a compiled template, such
+// as generator parameters, are not compiled again.
+func (g *Generator) generate(i int, template interface{}) (interface{}, error) {
+	n, err := g.compile(template)
+	if err != nil {
+		return nil, err
+	}
+	return n.resolve(g, i)
+}
+
+func (g *Generator) resolveVar(prefix string, variable string, params interface{}, i int) (interface{}, error) {
+	// Only prefixed strings are generators or variables
+	if !strings.HasPrefix(variable, prefix) {
+		return variable, nil
+	}
+	if literal, ok := g.unescape(variable); ok {
+		return literal, nil
+	}
+	trimmedVar := strings.TrimPrefix(variable, prefix)
+	switch trimmedVar {
+	case "int":
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			return g.intInRange("$int", paramsMap, i)
+		}
+		return nil, errors.New("$int requires a {min, max} object")
+	case "float":
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			min, minOk := convertToFloat(paramsMap["min"])
+			max, maxOk := convertToFloat(paramsMap["max"])
+			if !minOk || !maxOk {
+				return nil, errors.New("invalid min or max value for $float")
+			}
+			if max < min {
+				return nil, fmt.Errorf("$float: max (%v) must be >= min (%v)", max, min)
+			}
+			value := min + g.rng.Float64()*(max-min)
+			if p, exists := paramsMap["precision"]; exists {
+				precision, err := convertToInt(p)
+				if err != nil || precision < 0 {
+					return nil, errors.New("invalid precision value for $float")
+				}
+				value = roundTo(value, precision)
+			}
+			return value, nil
+		}
+		return nil, errors.New("$float requires a {min, max} object")
+	case "str":
+		if paramsList, ok := params.([]interface{}); ok {
+			var strBuilder strings.Builder
+			for _, elem := range paramsList {
+				resolved, err := g.Generate(i, elem)
+				if err != nil {
+					return nil, err
+				}
+				strBuilder.WriteString(fmt.Sprintf("%v", resolved))
+			}
+			return strBuilder.String(), nil
+		}
+
+		// In case of params is not an array, just a object
+		result, err := g.Generate(i, params)
+		if err != nil {
+			return nil, err
+		}
+		resultStr, err := joinAnySlice(result)
+		if err != nil {
+			return nil, err
+		}
+		return resultStr, nil
+
+	case "arr":
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			length, err := g.length("$arr", paramsMap["len"], i)
+			if err != nil {
+				return nil, err
+			}
+
+			val, valExists := paramsMap["val"]
+			if !valExists {
+				return nil, errors.New("missing val for $arr")
+			}
+
+			unique, _ := paramsMap["unique"].(bool)
+			seen := make(map[string]bool, length)
+
+			arr := make([]interface{}, length)
+			for z := 0; z < length; z++ {
+				if err := g.ctxErr(); err != nil {
+					return nil, err
+				}
+				var resolvedVal interface{}
+				// Without unique the first value is always kept; with it,
+				// values already placed are re-drawn
+				for attempt := 0; ; attempt++ {
+					if attempt == maxRetries {
+						return nil, fmt.Errorf("$arr: could not generate %d unique values after %d attempts", length, maxRetries)
+					}
+					resolvedVal, err = g.Generate(i, val)
+					if err != nil {
+						return nil, err
+					}
+					if !unique {
+						break
+					}
+					key, err := jsonKey(resolvedVal)
+					if err != nil {
+						return nil, err
+					}
+					if !seen[key] {
+						seen[key] = true
+						break
+					}
+				}
+				arr[z] = resolvedVal
+			}
+			return arr, nil
+
+		}
+		return nil, errors.New("$arr requires a {len, val} object")
+	case "obj":
+		if paramsList, ok := params.([]interface{}); ok && len(paramsList) > 0 {
+
+			randomIndex := g.rng.IntN(len(paramsList))
+			selectedObj, ok := paramsList[randomIndex].(map[string]interface{})
+			if !ok {
+				return nil, errors.New("$obj must contain a list of objects")
+			}
+			result, err := g.Generate(i, selectedObj)
+			if err != nil {
+				return nil, err
+			}
+			return result, nil
+
+		}
+		return nil, errors.New("$obj requires objects")
+	case "oneof":
+		if paramsList, ok := params.([]interface{}); ok {
+			if len(paramsList) == 0 {
+				return nil, errors.New("$oneof requires at least one value")
+			}
+			oneof, err := g.pickWeighted(paramsList)
+			if err != nil {
+				return nil, err
+			}
+			resolved, err := g.generate(i, oneof)
+			if err != nil {
+				return nil, err
+			}
+			return resolved, nil
+		}
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if list, ok := paramsMap["probabilities"].([]interface{}); ok {
+				oneof, err := g.pickProbable(list)
+				if err != nil {
+					return nil, err
+				}
+				return g.generate(i, oneof)
+			}
+		}
+		return nil, errors.New("$oneof requires a list of values or a {probabilities} object")
+	case "option":
+		if params == nil {
+			return nil, errors.New("$option requires a valid parameter")
+		}
+
+		// {"value": ..., "probability": p} includes the value with probability
+		// p (0.5 by default) and otherwise omits the enclosing key
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if value, exists := paramsMap["value"]; exists {
+				probability, err := probabilityParam("$option", paramsMap)
+				if err != nil {
+					return nil, err
+				}
+				if g.rng.Float64() >= probability {
+					return omitted, nil
+				}
+				return g.generate(i, value)
+			}
+		}
+
+		return g.generate(i, params)
+
+	case "person":
+		country := "US"
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if c, ok := paramsMap["country"].(string); ok {
+				country = strings.ToUpper(c)
+			}
+		} else if params != nil {
+			return nil, errors.New("$person requires a {country} object")
+		}
+		loc, err := lookupLocale(country)
+		if err != nil {
+			return nil, fmt.Errorf("$person: %w", err)
+		}
+		return loc.person(g.rng, country), nil
+
+	case "firstname", "lastname", "name":
+		country, gender := "US", ""
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["country"]; exists {
+				if country, ok = v.(string); !ok {
+					return nil, fmt.Errorf("country for $%s must be a string", trimmedVar)
+				}
+			}
+			if v, exists := paramsMap["gender"]; exists {
+				if gender, ok = v.(string); !ok {
+					return nil, fmt.Errorf("gender for $%s must be a string", trimmedVar)
+				}
+			}
+		} else if params != nil {
+			return nil, fmt.Errorf("$%s accepts an optional {country, gender} object", trimmedVar)
+		}
+		loc, err := lookupLocale(country)
+		if err != nil {
+			return nil, fmt.Errorf("$%s: %w", trimmedVar, err)
+		}
+		if trimmedVar == "lastname" {
+			return loc.lastNames[g.rng.IntN(len(loc.lastNames))], nil
+		}
+		first, err := loc.firstName(g.rng, strings.ToLower(gender))
+		if err != nil {
+			return nil, fmt.Errorf("$%s: %w", trimmedVar, err)
+		}
+		if trimmedVar == "firstname" {
+			return first, nil
+		}
+		return first + " " + loc.lastNames[g.rng.IntN(len(loc.lastNames))], nil
+	case "phone":
+		country := "US"
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["country"]; exists {
+				if country, ok = v.(string); !ok {
+					return nil, errors.New("country for $phone must be a string")
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$phone accepts an optional {country} object")
+		}
+		loc, err := lookupLocale(country)
+		if err != nil {
+			return nil, fmt.Errorf("$phone: %w", err)
+		}
+		return loc.phone(g.rng), nil
+	case "age":
+		birthdate, today, err := g.birthdateParams("$age", params, i)
+		if err != nil {
+			return nil, err
+		}
+		age, err := ageAt(birthdate, today)
+		if err != nil {
+			return nil, fmt.Errorf("$age: %w", err)
+		}
+		return age, nil
+	case "zodiac":
+		birthdate, _, err := g.birthdateParams("$zodiac", params, i)
+		if err != nil {
+			return nil, err
+		}
+		return zodiacSign(birthdate), nil
+
+	case "measurement":
+		return g.measurement(params)
+	case "subset":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$subset requires a {object, minKeys, maxKeys} object")
+		}
+		if err := checkParamNames("subset", paramsMap); err != nil {
+			return nil, err
+		}
+		resolved, err := g.Generate(i, paramsMap["object"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve object for $subset: %w", err)
+		}
+		obj, ok := asObject(resolved)
+		if !ok {
+			return nil, errors.New("$subset object must resolve to an object")
+		}
+
+		minKeys, maxKeys := 0, len(obj)
+		if v, exists := paramsMap["minKeys"]; exists {
+			var err error
+			if minKeys, err = convertToInt(v); err != nil {
+				return nil, fmt.Errorf("invalid minKeys value for $subset: %w", err)
+			}
+		}
+		if v, exists := paramsMap["maxKeys"]; exists {
+			var err error
+			if maxKeys, err = convertToInt(v); err != nil {
+				return nil, fmt.Errorf("invalid maxKeys value for $subset: %w", err)
+			}
+		}
+		if minKeys < 0 || maxKeys < minKeys || maxKeys > len(obj) {
+			return nil, fmt.Errorf("$subset: key range [%d, %d] is invalid for an object with %d keys", minKeys, maxKeys, len(obj))
+		}
+
+		keys := sortedKeys(obj)
+		n := g.rng.IntN(maxKeys-minKeys+1) + minKeys
+		subset := make(map[string]interface{}, n)
+		for _, idx := range g.rng.Perm(len(keys))[:n] {
+			subset[keys[idx]] = obj[keys[idx]]
+		}
+		// The kept keys stay in the order of the object they come from
+		order := keys
+		if ordered, ok := resolved.(*OrderedMap); ok {
+			order = ordered.Keys
+		} else if !g.preserveOrder {
+			return subset, nil
+		}
+		kept := make([]string, 0, n)
+		for _, key := range order {
+			if _, ok := subset[key]; ok {
+				kept = append(kept, key)
+			}
+		}
+		return &OrderedMap{Keys: kept, Values: subset}, nil
+	case "pick":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$pick requires a {from, n} object")
+		}
+		resolved, err := g.Generate(i, paramsMap["from"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve from for $pick: %w", err)
+		}
+		from, ok := resolved.([]interface{})
+		if !ok {
+			return nil, errors.New("$pick from must resolve to a list")
+		}
+		n, err := g.length("$pick", paramsMap["n"], i)
+		if err != nil {
+			return nil, err
+		}
+		if n > len(from) {
+			return nil, fmt.Errorf("$pick: cannot pick %d distinct elements from a list of %d", n, len(from))
+		}
+		picked := make([]interface{}, n)
+		for z, idx := range g.rng.Perm(len(from))[:n] {
+			picked[z] = from[idx]
+		}
+		return picked, nil
+
+	case "shuffle":
+		// A list is resolved element by element; anything else must
+		// resolve to a list
+		var list []interface{}
+		if elems, ok := params.([]interface{}); ok {
+			list = make([]interface{}, len(elems))
+			for z, elem := range elems {
+				var err error
+				if list[z], err = g.Generate(i, elem); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			resolved, err := g.Generate(i, params)
+			if err != nil {
+				return nil, err
+			}
+			elems, ok := resolved.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("$shuffle requires a list, got %T", resolved)
+			}
+			list = append([]interface{}{}, elems...)
+		}
+		g.rng.Shuffle(len(list), func(a, b int) {
+			list[a], list[b] = list[b], list[a]
+		})
+		return list, nil
+
+	case "line":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$line requires a {file} object")
+		}
+		path, ok := paramsMap["file"].(string)
+		if !ok {
+			return nil, errors.New("missing file for $line")
+		}
+		lines, err := g.readLines(path)
+		if err != nil {
+			return nil, fmt.Errorf("$line: %w", err)
+		}
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("$line: file %q is empty", path)
+		}
+		if wrap, _ := paramsMap["wrap"].(bool); wrap {
+			return lines[i%len(lines)], nil
+		}
+		if i >= len(lines) {
+			return nil, fmt.Errorf("$line: file %q has no line for index %d (%d lines)", path, i, len(lines))
+		}
+		return lines[i], nil
+
+	case "choice":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$choice requires a {file} or {values} object")
+		}
+		if values, exists := paramsMap["values"]; exists {
+			list, ok := values.([]interface{})
+			if !ok || len(list) == 0 {
+				return nil, errors.New("values for $choice must be a non-empty list")
+			}
+			return g.Generate(i, list[g.rng.IntN(len(list))])
+		}
+		path, ok := paramsMap["file"].(string)
+		if !ok {
+			return nil, errors.New("$choice requires a file or values")
+		}
+		lines, err := g.choiceLines(path)
+		if err != nil {
+			return nil, err
+		}
+		return lines[g.rng.IntN(len(lines))], nil
+
+	case "register":
+		// Add the resolved value to a named pool so later generators can refer to it
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$register requires a {pool, val} object")
+		}
+		pool, ok := paramsMap["pool"].(string)
+		if !ok {
+			return nil, errors.New("missing pool for $register")
+		}
+		resolved, err := g.Generate(i, paramsMap["val"])
+		if err != nil {
+			return nil, err
+		}
+		g.pools[pool] = append(g.pools[pool], resolved)
+		return resolved, nil
+	case "edge":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$edge requires a {from, to} object")
+		}
+		fromPool, _ := paramsMap["from"].(string)
+		toPool, _ := paramsMap["to"].(string)
+		allowSelf := true
+		if v, exists := paramsMap["allowSelf"]; exists {
+			if allowSelf, ok = v.(bool); !ok {
+				return nil, errors.New("allowSelf for $edge must be a boolean")
+			}
+		}
+		if len(g.pools[fromPool]) == 0 {
+			return nil, fmt.Errorf("$edge: pool %q is empty", fromPool)
+		}
+		if len(g.pools[toPool]) == 0 {
+			return nil, fmt.Errorf("$edge: pool %q is empty", toPool)
+		}
+
+		from := g.pools[fromPool][g.rng.IntN(len(g.pools[fromPool]))]
+		candidates := g.pools[toPool]
+		if !allowSelf {
+			candidates = nil
+			for _, id := range g.pools[toPool] {
+				if !reflect.DeepEqual(id, from) {
+					candidates = append(candidates, id)
+				}
+			}
+			if len(candidates) == 0 {
+				return nil, fmt.Errorf("$edge: pool %q has no id other than %v", toPool, from)
+			}
+		}
+		to := candidates[g.rng.IntN(len(candidates))]
+		return map[string]interface{}{"from": from, "to": to}, nil
+
+	case "padIndex":
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			width, err := convertToInt(paramsMap["width"])
+			if err != nil || width <= 0 {
+				return nil, errors.New("$padIndex requires a positive width")
+			}
+			return fmt.Sprintf("%0*d", width, i), nil
+		}
+		return nil, errors.New("$padIndex requires a {width} object")
+
+	case "except":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$except requires a {value, exclude} object")
+		}
+		exclude, ok := paramsMap["exclude"].([]interface{})
+		if !ok {
+			return nil, errors.New("$except requires an exclude list")
+		}
+		excluded := make(map[string]bool, len(exclude))
+		for _, v := range exclude {
+			key, err := jsonKey(v)
+			if err != nil {
+				return nil, err
+			}
+			excluded[key] = true
+		}
+
+		for attempt := 0; attempt < maxRetries; attempt++ {
+			resolved, err := g.Generate(i, paramsMap["value"])
+			if err != nil {
+				return nil, err
+			}
+			key, err := jsonKey(resolved)
+			if err != nil {
+				return nil, err
+			}
+			if !excluded[key] {
+				return resolved, nil
+			}
+		}
+		return nil, fmt.Errorf("$except: no allowed value after %d attempts", maxRetries)
+
+	case "split":
+		return g.split(params, i)
+
+	case "freqStr":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$freqStr requires a {length, freq} object")
+		}
+		length, err := g.length("$freqStr", paramsMap["length"], i)
+		if err != nil {
+			return nil, err
+		}
+		freq, ok := paramsMap["freq"].(map[string]interface{})
+		if !ok || len(freq) == 0 {
+			return nil, errors.New("$freqStr requires a non-empty freq object")
+		}
+
+		// Sorted keys keep the cumulative table stable across runs
+		chars := sortedKeys(freq)
+		cumulative := make([]float64, len(chars))
+		var total float64
+		for z, c := range chars {
+			weight, ok := convertToFloat(freq[c])
+			if !ok || weight < 0 {
+				return nil, fmt.Errorf("invalid frequency for %q in $freqStr", c)
+			}
+			total += weight
+			cumulative[z] = total
+		}
+		if total <= 0 {
+			return nil, errors.New("$freqStr frequencies must sum to a positive value")
+		}
+
+		var strBuilder strings.Builder
+		for z := 0; z < length; z++ {
+			r := g.rng.Float64() * total
+			idx := sort.Search(len(cumulative), func(k int) bool { return cumulative[k] > r })
+			strBuilder.WriteString(chars[idx])
+		}
+		return strBuilder.String(), nil
+
+	case "uuid":
+		version := 4
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["version"]; exists {
+				var err error
+				if version, err = convertToInt(v); err != nil {
+					return nil, fmt.Errorf("invalid version value for $uuid: %w", err)
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$uuid accepts an optional {version} object")
+		}
+		return g.uuid(version)
+
+	case "date":
+		t, err := g.randomInstant("$date", params)
+		if err != nil {
+			return nil, err
+		}
+		return formatInstant("$date", t, params, "2006-01-02")
+	case "datetime":
+		t, err := g.randomInstant("$datetime", params)
+		if err != nil {
+			return nil, err
+		}
+		return formatInstant("$datetime", t, params, time.RFC3339)
+	case "timestamp":
+		return g.timestamp(params)
+
+	case "regex":
+		maxRepeat := defaultMaxRepeat
+		switch p := params.(type) {
+		case string:
+			return g.regexString(p, maxRepeat)
+		case map[string]interface{}:
+			pattern, ok := p["pattern"].(string)
+			if !ok {
+				return nil, errors.New("missing pattern for $regex")
+			}
+			if v, exists := p["maxRepeat"]; exists {
+				var err error
+				if maxRepeat, err = convertToInt(v); err != nil || maxRepeat < 0 {
+					return nil, errors.New("invalid maxRepeat value for $regex")
+				}
+			}
+			return g.regexString(pattern, maxRepeat)
+		}
+		return nil, errors.New("$regex requires a pattern string or a {pattern, maxRepeat} object")
+
+	case "ref":
+		path, ok := params.(string)
+		if !ok {
+			return nil, errors.New("$ref requires a field path string")
+		}
+		return g.lookupRef(path)
+
+	case "seq":
+		// Counters with the same name share state across records; the
+		// start of the first call begins the sequence. Unnamed counters are
+		// keyed by the path of the field they generate.
+		name, start, step := "field:"+g.fieldPath(), 0, 1
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["name"]; exists {
+				if name, ok = v.(string); !ok {
+					return nil, errors.New("name for $seq must be a string")
+				}
+			}
+			if v, exists := paramsMap["start"]; exists {
+				var err error
+				if start, err = convertToInt(v); err != nil {
+					return nil, fmt.Errorf("invalid start value for $seq: %w", err)
+				}
+			}
+			if v, exists := paramsMap["step"]; exists {
+				var err error
+				if step, err = convertToInt(v); err != nil {
+					return nil, fmt.Errorf("invalid step value for $seq: %w", err)
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$seq accepts an optional {name, start, step} object")
+		}
+		value, exists := g.seqs[name]
+		if !exists {
+			value = start
+		}
+		g.seqs[name] = value + step
+		return value, nil
+	case "incr":
+		// Unlike $seq, the counter advances once per record rather than
+		// once per call, so every $incr of one field path in a record
+		// (such as the elements of an $arr) gets the same value
+		path := g.fieldPath()
+		state, exists := g.incrs[path]
+		if !exists {
+			g.incrs[path] = &incrState{record: i}
+			return 0, nil
+		}
+		if i != state.record {
+			state.value++
+			state.record = i
+		}
+		return state.value, nil
+	case "seeded":
+		// val draws from its own rng seeded by seed, and the main rng
+		// continues as if val had not been generated
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$seeded requires a {seed, val} object")
+		}
+		resolvedSeed, err := g.Generate(i, paramsMap["seed"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve seed for $seeded: %w", err)
+		}
+		seed, err := convertToInt(resolvedSeed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed value for $seeded: %w", err)
+		}
+		mainRng := g.rng
+		g.rng = rand.New(rand.NewPCG(uint64(seed), uint64(seed)))
+		defer func() { g.rng = mainRng }()
+		return g.generate(i, paramsMap["val"])
+
+	case "email":
+		domain := ""
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["domain"]; exists {
+				if domain, ok = v.(string); !ok || domain == "" {
+					return nil, errors.New("domain for $email must be a non-empty string")
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$email accepts an optional {domain} object")
+		}
+		return g.email(domain), nil
+
+	case "ipv4":
+		cidr := ""
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if cidr, ok = paramsMap["cidr"].(string); !ok {
+				return nil, errors.New("$ipv4 requires a cidr string")
+			}
+		} else if params != nil {
+			return nil, errors.New("$ipv4 accepts an optional {cidr} object")
+		}
+		return g.ipv4(cidr)
+	case "ipv6":
+		return g.ipv6(), nil
+
+	case "lower", "upper", "title":
+		return g.transformCase(trimmedVar, params, i)
+	case "format":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$format requires a {fmt, args} object")
+		}
+		format, ok := paramsMap["fmt"].(string)
+		if !ok {
+			return nil, errors.New("fmt for $format must be a string")
+		}
+		var args []interface{}
+		if v, exists := paramsMap["args"]; exists {
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, errors.New("args for $format must be a list")
+			}
+			for _, elem := range list {
+				resolved, err := g.Generate(i, elem)
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, resolved)
+			}
+		}
+		result, err := formatString(format, args)
+		if err != nil {
+			return nil, fmt.Errorf("$format: %w", err)
+		}
+		return result, nil
+	case "join":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$join requires a {sep, values} object")
+		}
+		sep, ok := paramsMap["sep"].(string)
+		if !ok {
+			return nil, errors.New("sep for $join must be a string")
+		}
+		values, ok := paramsMap["values"].([]interface{})
+		if !ok {
+			return nil, errors.New("values for $join must be a list")
+		}
+		parts := make([]string, len(values))
+		for z, elem := range values {
+			part, err := g.stringify("join", elem, i)
+			if err != nil {
+				return nil, err
+			}
+			parts[z] = part
+		}
+		return strings.Join(parts, sep), nil
+	case "unique":
+		// Values are distinct across all records of the run for the field
+		// the $unique generates
+		path := g.fieldPath()
+		seen, exists := g.uniques[path]
+		if !exists {
+			seen = make(map[string]bool)
+			g.uniques[path] = seen
+		}
+		for attempt := 0; attempt < maxRetries; attempt++ {
+			value, err := g.Generate(i, params)
+			if err != nil {
+				return nil, err
+			}
+			key, err := jsonKey(value)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[key] {
+				seen[key] = true
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("$unique: could not generate a value not seen before after %d attempts", maxRetries)
+	case "hex":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$hex requires a {len} object")
+		}
+		length, err := g.length("$hex", paramsMap["len"], i)
+		if err != nil {
+			return nil, err
+		}
+		uppercase, _ := paramsMap["uppercase"].(bool)
+		if uppercase {
+			return g.randomString("0123456789ABCDEF", length), nil
+		}
+		return g.randomString("0123456789abcdef", length), nil
+	case "bytes":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$bytes requires a {len} object")
+		}
+		length, err := g.length("$bytes", paramsMap["len"], i)
+		if err != nil {
+			return nil, err
+		}
+		encoding := "base64"
+		if v, exists := paramsMap["encoding"]; exists {
+			if encoding, ok = v.(string); !ok {
+				return nil, errors.New("encoding for $bytes must be a string")
+			}
+		}
+		data := make([]byte, length)
+		for z := range data {
+			data[z] = byte(g.rng.UintN(256))
+		}
+		switch encoding {
+		case "base64":
+			return base64.StdEncoding.EncodeToString(data), nil
+		case "base64url":
+			return base64.URLEncoding.EncodeToString(data), nil
+		case "hex":
+			return hex.EncodeToString(data), nil
+		default:
+			return nil, fmt.Errorf("$bytes: unknown encoding %q (supported: base64, base64url, hex)", encoding)
+		}
+	case "password":
+		return g.password(params, i)
+	case "alnum":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$alnum requires a {len} object")
+		}
+		length, err := g.length("$alnum", paramsMap["len"], i)
+		if err != nil {
+			return nil, err
+		}
+		charset := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+		if v, exists := paramsMap["charset"]; exists {
+			if charset, ok = v.(string); !ok || charset == "" {
+				return nil, errors.New("charset for $alnum must be a non-empty string")
+			}
+		}
+		return g.randomString(charset, length), nil
+	case "map":
+		return g.mapItems(params, i)
+	case "repeat":
+		// Unlike $arr, val is resolved once and shared by every element
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$repeat requires a {n, val} object")
+		}
+		resolvedN, err := g.Generate(i, paramsMap["n"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve n for $repeat: %w", err)
+		}
+		n, err := convertToInt(resolvedN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n value for $repeat: %w", err)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("$repeat: n must not be negative, got %d", n)
+		}
+		value, err := g.Generate(i, paramsMap["val"])
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, n)
+		for z := range arr {
+			arr[z] = value
+		}
+		return arr, nil
+	case "expr":
+		return g.expr(params, i)
+	case "if":
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$if requires a {cond, then, else} object")
+		}
+		cond, err := g.Generate(i, paramsMap["cond"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve cond for $if: %w", err)
+		}
+		truthy, ok := cond.(bool)
+		if !ok {
+			return nil, fmt.Errorf("cond for $if must resolve to a boolean, got %T", cond)
+		}
+		if truthy {
+			return g.generate(i, paramsMap["then"])
+		}
+		// Without an else branch, the enclosing key is omitted
+		elseValue, exists := paramsMap["else"]
+		if !exists {
+			return omitted, nil
+		}
+		return g.generate(i, elseValue)
+	case "lorem":
+		unit, count := "words", 5
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["unit"]; exists {
+				if unit, ok = v.(string); !ok {
+					return nil, errors.New("unit for $lorem must be a string")
+				}
+			}
+			if v, exists := paramsMap["count"]; exists {
+				var err error
+				if count, err = g.length("$lorem", v, i); err != nil {
+					return nil, err
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$lorem accepts an optional {unit, count} object")
+		}
+		text, err := g.lorem(unit, count)
+		if err != nil {
+			return nil, fmt.Errorf("$lorem: %w", err)
+		}
+		return text, nil
+	case "const":
+		// Emitted verbatim, without resolving anything inside
+		return params, nil
+	case "null":
+		return nil, nil
+	case "nullable":
+		// Unlike $option, the key is kept and its value becomes null
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("$nullable requires a {value, probability} object")
+		}
+		value, exists := paramsMap["value"]
+		if !exists {
+			return nil, errors.New("missing value for $nullable")
+		}
+		probability, err := probabilityParam("$nullable", paramsMap)
+		if err != nil {
+			return nil, err
+		}
+		if g.rng.Float64() < probability {
+			return nil, nil
+		}
+		return g.Generate(i, value)
+	case "url":
+		scheme, withPath, withQuery := "", true, false
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["scheme"]; exists {
+				if scheme, ok = v.(string); !ok || (scheme != "http" && scheme != "https") {
+					return nil, errors.New(`scheme for $url must be "http" or "https"`)
+				}
+			}
+			if v, exists := paramsMap["path"]; exists {
+				if withPath, ok = v.(bool); !ok {
+					return nil, errors.New("path for $url must be a boolean")
+				}
+			}
+			if v, exists := paramsMap["query"]; exists {
+				if withQuery, ok = v.(bool); !ok {
+					return nil, errors.New("query for $url must be a boolean")
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$url accepts an optional {scheme, path, query} object")
+		}
+		return g.url(scheme, withPath, withQuery), nil
+	case "company":
+		suffix := true
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["suffix"]; exists {
+				if suffix, ok = v.(bool); !ok {
+					return nil, errors.New("suffix for $company must be a boolean")
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$company accepts an optional {suffix} object")
+		}
+		return g.company(suffix), nil
+	case "jobtitle":
+		return g.jobTitle(), nil
+	case "department":
+		return departments[g.rng.IntN(len(departments))], nil
+	case "useragent":
+		kind := ""
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["type"]; exists {
+				if kind, ok = v.(string); !ok {
+					return nil, errors.New("type for $useragent must be a string")
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$useragent accepts an optional {type} object")
+		}
+		ua, err := g.userAgent(kind)
+		if err != nil {
+			return nil, fmt.Errorf("$useragent: %w", err)
+		}
+		return ua, nil
+	case "color":
+		return cssColors[g.rng.IntN(len(cssColors))], nil
+	case "hexcolor":
+		alpha := false
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["alpha"]; exists {
+				if alpha, ok = v.(bool); !ok {
+					return nil, errors.New("alpha for $hexcolor must be a boolean")
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$hexcolor accepts an optional {alpha} object")
+		}
+		return g.hexColor(alpha), nil
+	case "creditcard":
+		network, grouped := "", false
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if v, exists := paramsMap["network"]; exists {
+				if network, ok = v.(string); !ok {
+					return nil, errors.New("network for $creditcard must be a string")
+				}
+			}
+			if v, exists := paramsMap["grouped"]; exists {
+				if grouped, ok = v.(bool); !ok {
+					return nil, errors.New("grouped for $creditcard must be a boolean")
+				}
+			}
+		} else if params != nil {
+			return nil, errors.New("$creditcard accepts an optional {network, grouped} object")
+		}
+		number, err := g.creditCard(network, grouped)
+		if err != nil {
+			return nil, fmt.Errorf("$creditcard: %w", err)
+		}
+		return number, nil
+	case "semver":
+		return g.semver(params)
+	case "latlng":
+		return g.latLng(params)
+	case "i":
+		return i, nil // return iteration value
+	case "count":
+		return g.count, nil
+	case "u8":
+		return uint8(g.rng.UintN(256)), nil
+	case "u16":
+		return uint16(g.rng.UintN(65536)), nil
+	case "u32":
+		return g.rng.Uint32(), nil
+	case "i8":
+		return int8(g.rng.IntN(256) - 128), nil
+	case "i16":
+		return int16(g.rng.IntN(65536) - 32768), nil
+	case "i32":
+		return g.rng.Int32(), nil
+	case "i64":
+		return g.rng.Int64(), nil
+	case "digit":
+		return g.rng.IntN(10), nil
+	case "bool":
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			probability, err := probabilityParam("$bool", paramsMap)
+			if err != nil {
+				return nil, err
+			}
+			return g.rng.Float64() < probability, nil
+		} else if params != nil {
+			return nil, errors.New("$bool accepts an optional {probability} object")
+		}
+		return g.rng.IntN(2) == 1, nil
+	case "alpha":
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			length := 1
+			if v, exists := paramsMap["len"]; exists {
+				var err error
+				if length, err = g.length("$alpha", v, i); err != nil {
+					return nil, err
+				}
+			}
+			letters := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+			if v, exists := paramsMap["case"]; exists {
+				switch v {
+				case "upper":
+					letters = letters[:26]
+				case "lower":
+					letters = letters[26:]
+				case "mixed":
+				default:
+					return nil, fmt.Errorf(`$alpha: case must be "upper", "lower", or "mixed", got %v`, v)
+				}
+			}
+			return g.randomString(letters, length), nil
+		} else if params != nil {
+			return nil, errors.New("$alpha accepts an optional {case, len} object")
+		}
+		if g.rng.IntN(2) == 0 {
+			return string(rune('a' + g.rng.IntN(26))), nil
+		}
+		return string(rune('A' + g.rng.IntN(26))), nil
+	default:
+		if strings.HasPrefix(trimmedVar, "ref:") {
+			return g.lookupRef(strings.TrimPrefix(trimmedVar, "ref:"))
+		}
+		// Variables bound by $map hold generated values, emitted as-is
+		if local, isExist := g.locals[trimmedVar]; isExist {
+			return local, nil
+		}
+		// handle user-defined variables
+		if userdefinedVar, isExist := g.vars[trimmedVar]; isExist {
+			if g.depth >= g.maxDepth {
+				return nil, &depthError{name: variable, limit: g.maxDepth}
+			}
+			g.depth++
+			result, err := g.generate(i, userdefinedVar)
+			g.depth--
+			if isDepthError(err) {
+				return nil, err
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve variable %q: %w", variable, err)
+			}
+			return result, nil
+		}
+		return nil, fmt.Errorf("undefined variable: %q", variable)
+	}
+}
+
+// split assigns record i to a cohort by position, so that cohorts cover
+// contiguous index ranges proportional to their fractions of the total count.
+// Cohorts are assigned in the order they are listed, either as the keys of
+// an object parsed by Parse or as a list of single-key objects.
+func (g *Generator) split(params interface{}, i int) (interface{}, error) {
+	type cohort struct {
+		name     string
+		fraction float64
+	}
+	var cohorts []cohort
+	addCohorts := func(m map[string]interface{}) error {
+		names, ok := g.keyOrder(m)
+		if !ok {
+			if len(m) > 1 {
+				return errors.New("$split: the order of cohorts given as an object is unknown unless the template is parsed with Parse; list them as single-key objects instead")
+			}
+			names = sortedKeys(m)
+		}
+		for _, name := range names {
+			fraction, ok := convertToFloat(m[name])
+			if !ok || fraction < 0 {
+				return fmt.Errorf("invalid fraction for cohort %q in $split", name)
+			}
+			cohorts = append(cohorts, cohort{name, fraction})
+		}
+		return nil
+	}
+	switch p := params.(type) {
+	case map[string]interface{}:
+		if err := addCohorts(p); err != nil {
+			return nil, err
+		}
+	case []interface{}:
+		for _, elem := range p {
+			m, ok := elem.(map[string]interface{})
+			if !ok || len(m) != 1 {
+				return nil, errors.New("$split list entries must be single-key objects")
+			}
+			if err := addCohorts(m); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.New("$split requires an object of cohort fractions")
+	}
+	if len(cohorts) == 0 {
+		return nil, errors.New("$split requires at least one cohort")
+	}
+
+	var sum float64
+	for _, c := range cohorts {
+		sum += c.fraction
+	}
+	if math.Abs(sum-1) > 1e-6 {
+		return nil, fmt.Errorf("$split fractions must sum to 1, got %v", sum)
+	}
+	if g.count <= 0 {
+		return nil, errors.New("$split requires a known record count")
+	}
+
+	var cumulative float64
+	for _, c := range cohorts[:len(cohorts)-1] {
+		cumulative += c.fraction
+		if i < int(math.Round(cumulative*float64(g.count))) {
+			return c.name, nil
+		}
+	}
+	return cohorts[len(cohorts)-1].name, nil
+}
+
+// intInRange returns a random int between the "min" and "max" fields of
+// paramsMap, inclusive. Both bounds may be generators.
+func (g *Generator) intInRange(name string, paramsMap map[string]interface{}, i int) (int, error) {
+	resolvedMin, err := g.Generate(i, paramsMap["min"])
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve min for %s: %w", name, err)
+	}
+	resolvedMax, err := g.Generate(i, paramsMap["max"])
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve max for %s: %w", name, err)
+	}
+	min, err := convertToInt(resolvedMin)
+	if err != nil {
+		return 0, fmt.Errorf("invalid min value for %s: %w", name, err)
+	}
+	max, err := convertToInt(resolvedMax)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max value for %s: %w", name, err)
+	}
+	if max < min {
+		return 0, fmt.Errorf("%s: max (%d) must be >= min (%d)", name, max, min)
+	}
+	if max == min {
+		return min, nil
+	}
+	// Compute the span unsigned so that ranges up to the full width of int
+	// do not overflow
+	span := uint64(max) - uint64(min)
+	if span == math.MaxUint64 {
+		return int(g.rng.Uint64()), nil
+	}
+	return int(uint64(min) + g.rng.Uint64N(span+1)), nil
+}
+
+// length resolves a length parameter, which is either a number, a generator
+// producing one, or a {min, max} range to pick from.
+func (g *Generator) length(name string, template interface{}, i int) (int, error) {
+	var length int
+	if lenRange, ok := template.(map[string]interface{}); ok && lenRange["min"] != nil && lenRange["max"] != nil {
+		var err error
+		if length, err = g.intInRange(name+" len", lenRange, i); err != nil {
+			return 0, err
+		}
+	} else {
+		resolvedLen, err := g.Generate(i, template)
+		if err != nil {
+			return 0, fmt.Errorf("failed to resolve length for %s: %w", name, err)
+		}
+		if length, err = convertToInt(resolvedLen); err != nil {
+			return 0, fmt.Errorf("invalid len value for %s: %w", name, err)
+		}
+	}
+	if length < 0 {
+		return 0, fmt.Errorf("%s: len must not be negative, got %d", name, length)
+	}
+	return length, nil
+}
+
+// probabilityParam returns the "probability" parameter of the named
+// generator, 0.5 when it is not given.
+func probabilityParam(name string, paramsMap map[string]interface{}) (float64, error) {
+	p, exists := paramsMap["probability"]
+	if !exists {
+		return 0.5, nil
+	}
+	probability, ok := convertToFloat(p)
+	if !ok || probability < 0 || probability > 1 {
+		return 0, fmt.Errorf("%s probability must be a number between 0 and 1", name)
+	}
+	return probability, nil
+}
+
+// pickProbable picks the value of one of the {"p": P, "v": ...} entries in
+// list, each with probability P. The probabilities must sum to 1.
+func (g *Generator) pickProbable(list []interface{}) (interface{}, error) {
+	if len(list) == 0 {
+		return nil, errors.New("$oneof probabilities must not be empty")
+	}
+	cumulative := make([]float64, len(list))
+	values := make([]interface{}, len(list))
+	var total float64
+	for z, elem := range list {
+		m, _ := elem.(map[string]interface{})
+		rawP, hasP := m["p"]
+		value, hasV := m["v"]
+		if len(m) != 2 || !hasP || !hasV {
+			return nil, errors.New("$oneof probabilities must be {p, v} objects")
+		}
+		p, ok := convertToFloat(rawP)
+		if !ok || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid probability %v in $oneof", rawP)
+		}
+		total += p
+		cumulative[z] = total
+		values[z] = value
+	}
+	if math.Abs(total-1) > 1e-6 {
+		return nil, fmt.Errorf("$oneof probabilities must sum to 1, got %v", total)
+	}
+
+	r := g.rng.Float64() * total
+	idx := sort.Search(len(cumulative), func(k int) bool { return cumulative[k] > r })
+	return values[idx], nil
+}
+
+// weightedEntry reports whether elem has the {"weight": N, "value": ...} form
+// and returns its parts.
+func weightedEntry(elem interface{}) (weight interface{}, value interface{}, ok bool) {
+	m, isMap := elem.(map[string]interface{})
+	if !isMap || len(m) != 2 {
+		return nil, nil, false
+	}
+	weight, hasWeight := m["weight"]
+	value, hasValue := m["value"]
+	return weight, value, hasWeight && hasValue
+}
+
+// pickWeighted chooses one element of list. Plain lists are sampled
+// uniformly; if the elements are {"weight", "value"} objects, a value is
+// chosen with probability proportional to its weight.
+func (g *Generator) pickWeighted(list []interface{}) (interface{}, error) {
+	if _, _, weighted := weightedEntry(list[0]); !weighted {
+		return list[g.rng.IntN(len(list))], nil
+	}
+
+	cumulative := make([]float64, len(list))
+	values := make([]interface{}, len(list))
+	var total float64
+	for z, elem := range list {
+		rawWeight, value, ok := weightedEntry(elem)
+		if !ok {
+			return nil, errors.New("$oneof cannot mix weighted and unweighted values")
+		}
+		weight, ok := convertToFloat(rawWeight)
+		if !ok || weight < 0 {
+			return nil, fmt.Errorf("invalid weight %v in $oneof", rawWeight)
+		}
+		total += weight
+		cumulative[z] = total
+		values[z] = value
+	}
+	if total <= 0 {
+		return nil, errors.New("$oneof weights must sum to a positive value")
+	}
+
+	r := g.rng.Float64() * total
+	idx := sort.Search(len(cumulative), func(k int) bool { return cumulative[k] > r })
+	return values[idx], nil
+}
+
+// readLines returns the lines of the file at path, reading it only once per
+// Generator.
+func (g *Generator) readLines(path string) ([]string, error) {
+	if lines, ok := g.files[path]; ok {
+		return lines, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for z, line := range lines {
+		lines[z] = strings.TrimSuffix(line, "\r")
+	}
+	if len(data) == 0 {
+		lines = nil
+	}
+	g.files[path] = lines
+	return lines, nil
+}
+
+// choiceLines returns the lines of a $choice file, which must not be empty.
+func (g *Generator) choiceLines(path string) ([]string, error) {
+	lines, err := g.readLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("$choice: %w", err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("$choice: file %q is empty", path)
+	}
+	return lines, nil
+}
+
+// jsonKey returns the JSON encoding of v, so values that decode differently
+// (e.g. int and float64) can be compared.
+func jsonKey(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// convertToInt converts a decoded JSON number, a generated integer, or a
+// numeric string to an int. Values with a fractional part or outside the
+// range of int are rejected rather than truncated.
+func convertToInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int8:
+		return int(v), nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case uint8:
+		return int(v), nil
+	case uint16:
+		return int(v), nil
+	case uint32:
+		return int(v), nil
+	case uint:
+		return uintToInt(uint64(v))
+	case uint64:
+		return uintToInt(v)
+	case float64:
+		return floatToInt(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return floatToInt(f)
+	case string:
+		if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			return int(n), nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return floatToInt(f)
+	default:
+		return 0, fmt.Errorf("expected an integer, got %T", value)
+	}
+}
+
+func uintToInt(v uint64) (int, error) {
+	if v > math.MaxInt {
+		return 0, fmt.Errorf("%d overflows int", v)
+	}
+	return int(v), nil
+}
+
+func floatToInt(v float64) (int, error) {
+	if v != math.Trunc(v) {
+		return 0, fmt.Errorf("%v has a fractional part", v)
+	}
+	// float64(math.MaxInt) rounds up to 2^63, which is out of range
+	if v < math.MinInt || v >= math.MaxInt {
+		return 0, fmt.Errorf("%v overflows int", v)
+	}
+	return int(v), nil
+}
+
+// convertToFloat accepts the same integer types as convertToInt, so that a
+// float parameter takes any integer a variable or generator produces.
+func convertToFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func joinAnySlice(result interface{}) (string, error) {
+	v := reflect.ValueOf(result)
+
+	if v.Kind() != reflect.Slice {
+		return "", fmt.Errorf("expected slice but got %T", result)
+	}
+
+	var strSlice []string
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		strSlice = append(strSlice, fmt.Sprint(elem.Interface()))
+	}
+
+	return strings.Join(strSlice, ""), nil
+}
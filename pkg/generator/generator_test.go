@@ -0,0 +1,709 @@
+package generator
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// generateRecords parses template, validates and compiles it, and generates
+// count records from a generator seeded with seed.
+func generateRecords(t *testing.T, template string, count int, seed uint64, opts ...Option) []interface{} {
+	t.Helper()
+	records, err := tryGenerateRecords(template, count, seed, opts...)
+	if err != nil {
+		t.Fatalf("generating %s: %v", template, err)
+	}
+	return records
+}
+
+// tryGenerateRecords is like generateRecords but returns the error, along
+// with the records generated before it.
+func tryGenerateRecords(template string, count int, seed uint64, opts ...Option) ([]interface{}, error) {
+	opts = append([]Option{WithSource(rand.NewPCG(seed, seed)), WithCount(count)}, opts...)
+	g, err := New(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := g.Parse([]byte(template))
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Validate(parsed); err != nil {
+		return nil, err
+	}
+	plan, err := g.Compile(parsed)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]interface{}, count)
+	for i := range records {
+		if records[i], err = plan.Generate(i); err != nil {
+			return records[:i], err
+		}
+	}
+	return records, nil
+}
+
+// assertDeterministic checks that two runs of template with the same seed
+// generate the same records.
+func assertDeterministic(t *testing.T, template string, count int) {
+	t.Helper()
+	first := generateRecords(t, template, count, 42)
+	second := generateRecords(t, template, count, 42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("runs of %s with the same seed differ:\n%v\n%v", template, first, second)
+	}
+}
+
+// assertGenerateError checks that generating template fails with an error
+// containing want.
+func assertGenerateError(t *testing.T, template, want string) {
+	t.Helper()
+	_, err := tryGenerateRecords(template, 1, 1)
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("generating %s: got error %v, want one containing %q", template, err, want)
+	}
+}
+
+// field returns the value of key in each record.
+func field(records []interface{}, key string) []interface{} {
+	values := make([]interface{}, len(records))
+	for z, record := range records {
+		obj, _ := asObject(record)
+		values[z] = obj[key]
+	}
+	return values
+}
+
+func TestSplitAssignsCohortsInListedOrder(t *testing.T) {
+	want := []interface{}{"train", "train", "train", "train", "train", "train", "train", "train", "val", "test"}
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{"object", `{"s": {"$split": {"train": 0.8, "val": 0.1, "test": 0.1}}}`},
+		{"list", `{"s": {"$split": [{"train": 0.8}, {"val": 0.1}, {"test": 0.1}]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := field(generateRecords(t, tt.template, 10, 1), "s")
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"fractions do not sum to 1", `{"s": {"$split": {"a": 0.5, "b": 0.2}}}`, "must sum to 1"},
+		{"negative fraction", `{"s": {"$split": {"a": -0.5, "b": 1.5}}}`, "invalid fraction"},
+		{"not an object", `{"s": {"$split": 1}}`, "requires an object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tryGenerateRecords(tt.template, 4, 1)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitObjectWithoutKeyOrder(t *testing.T) {
+	template, err := ParseTemplate([]byte(`{"$split": {"train": 0.5, "test": 0.5}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(nil, WithCount(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Generate(0, template); err == nil || !strings.Contains(err.Error(), "order of cohorts") {
+		t.Errorf("got error %v, want one about the order of cohorts", err)
+	}
+}
+
+func TestCountIsAGenerator(t *testing.T) {
+	g, err := New(nil, WithCount(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := g.Parse([]byte(`{"n": "$count", "m": {"$count": null}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Validate(template); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	got := generateRecords(t, `{"n": "$count"}`, 3, 1)
+	for _, n := range field(got, "n") {
+		if n != 3 {
+			t.Errorf("got $count %v, want 3", n)
+		}
+	}
+}
+
+func TestUniqueFailsWhenValuesRunOut(t *testing.T) {
+	records, err := tryGenerateRecords(`{"id":{"$unique":"$u8"}}`, 1000, 1)
+	if err == nil || !strings.Contains(err.Error(), "$unique: could not generate a value not seen before") {
+		t.Fatalf("got error %v, want $unique to run out of values", err)
+	}
+	// Every value a u8 can take is generated before giving up
+	seen := make(map[interface{}]bool)
+	for _, id := range field(records, "id") {
+		if seen[id] {
+			t.Fatalf("value %v generated twice", id)
+		}
+		seen[id] = true
+	}
+	if len(records) > 256 {
+		t.Errorf("generated %d records, more than the 256 values of a u8", len(records))
+	}
+}
+
+func TestSubset(t *testing.T) {
+	tests := []struct {
+		name             string
+		params           string
+		minKeys, maxKeys int
+	}{
+		{"range", `{"object":{"a":1,"b":"$u8","c":3,"d":4},"minKeys":1,"maxKeys":3}`, 1, 3},
+		{"exact", `{"object":{"a":1,"b":2,"c":3},"minKeys":2,"maxKeys":2}`, 2, 2},
+		{"defaults", `{"object":{"a":1,"b":2,"c":3}}`, 0, 3},
+		{"all keys", `{"object":{"a":1,"b":2},"minKeys":2}`, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := `{"s":{"$subset":` + tt.params + `}}`
+			for _, v := range field(generateRecords(t, template, 50, 1), "s") {
+				obj := v.(map[string]interface{})
+				if len(obj) < tt.minKeys || len(obj) > tt.maxKeys {
+					t.Errorf("kept %d keys, want %d to %d: %v", len(obj), tt.minKeys, tt.maxKeys, obj)
+				}
+				for key, value := range obj {
+					if key != "b" && value != int64(key[0]-'a'+1) {
+						t.Errorf("key %q has value %v, not the value from the object", key, value)
+					}
+				}
+			}
+			assertDeterministic(t, template, 5)
+		})
+	}
+}
+
+func TestSubsetErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`[1,2]`, "$subset requires a {object} object"},
+		{`{"object":[1,2]}`, "$subset object must resolve to an object"},
+		{`{"object":{"a":1},"minKeys":2}`, "$subset: key range [2, 1] is invalid for an object with 1 keys"},
+		{`{"object":{"a":1,"b":2},"maxKeys":3}`, "$subset: key range [0, 3] is invalid for an object with 2 keys"},
+		{`{"object":{"a":1},"minKeys":-1}`, "$subset: key range [-1, 1] is invalid"},
+		{`{"object":{"a":1},"maxKeys":"x"}`, "invalid maxKeys value for $subset"},
+		{`{"object":{"a":1},"min":1,"max":1}`, `$subset: unknown parameter "max" (supported: object, minKeys, maxKeys)`},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"s":{"$subset":`+tt.params+`}}`, tt.want)
+	}
+}
+
+func TestSubsetPreservesOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		want   string
+	}{
+		{"all keys", `{"object":{"z":1,"a":2,"m":3},"minKeys":3,"maxKeys":3}`, `{"z":1,"a":2,"m":3}`},
+		// $const objects carry no key order, so their keys are sorted
+		{"unordered object", `{"object":{"$const":{"z":1,"a":2,"m":3}},"minKeys":3}`, `{"a":2,"m":3,"z":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, v := range field(generateRecords(t, `{"s":{"$subset":`+tt.params+`}}`, 5, 1, WithPreserveOrder()), "s") {
+				got, err := json.Marshal(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want {
+					t.Errorf("got %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+	// Kept keys of a partial subset are in the object's order too
+	for _, v := range field(generateRecords(t, `{"s":{"$subset":{"object":{"z":1,"a":2,"m":3},"minKeys":2,"maxKeys":2}}}`, 20, 1, WithPreserveOrder()), "s") {
+		keys := v.(*OrderedMap).Keys
+		if !slices.IsSortedFunc(keys, func(a, b string) int { return strings.Index("zam", a) - strings.Index("zam", b) }) {
+			t.Errorf("got keys %v, want them in the order z, a, m", keys)
+		}
+	}
+}
+
+// writeFile writes content to a file in a temporary directory and returns
+// its path, escaped for use in a JSON template.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return strings.ReplaceAll(path, `\`, `\\`)
+}
+
+func TestLine(t *testing.T) {
+	labels := writeFile(t, "labels.txt", "cat\r\ndog\nbird\n")
+	tests := []struct {
+		name   string
+		params string
+		count  int
+		want   []interface{}
+	}{
+		{"aligned", `{"file":"` + labels + `"}`, 3, []interface{}{"cat", "dog", "bird"}},
+		{"wrap", `{"file":"` + labels + `","wrap":true}`, 5, []interface{}{"cat", "dog", "bird", "cat", "dog"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := field(generateRecords(t, `{"label":{"$line":`+tt.params+`}}`, tt.count, 1), "label")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineErrors(t *testing.T) {
+	labels := writeFile(t, "labels.txt", "cat\ndog\n")
+	empty := writeFile(t, "empty.txt", "")
+	missing := strings.ReplaceAll(filepath.Join(t.TempDir(), "missing.txt"), `\`, `\\`)
+
+	_, err := tryGenerateRecords(`{"label":{"$line":{"file":"`+labels+`"}}}`, 3, 1)
+	if err == nil || !strings.Contains(err.Error(), "has no line for index 2 (2 lines)") {
+		t.Errorf("got error %v, want running past the last line to fail", err)
+	}
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"file":"` + empty + `"}`, "is empty"},
+		{`{"file":"` + missing + `"}`, "missing.txt"},
+		{`"labels.txt"`, "$line requires a {file} object"},
+		{`{"wrap":true}`, "missing file for $line"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"label":{"$line":`+tt.params+`}}`, tt.want)
+	}
+}
+
+func TestEdge(t *testing.T) {
+	generate := func(seed uint64) []interface{} {
+		g, err := New(nil, WithSource(rand.NewPCG(seed, seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Register nodes 0 to 4, then connect them
+		for i := 0; i < 5; i++ {
+			if _, err := g.Generate(i, map[string]interface{}{"$register": map[string]interface{}{"pool": "nodes", "val": "$i"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		edge := map[string]interface{}{"$edge": map[string]interface{}{"from": "nodes", "to": "nodes", "allowSelf": false}}
+		edges := make([]interface{}, 30)
+		for i := range edges {
+			if edges[i], err = g.Generate(i, edge); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return edges
+	}
+
+	edges := generate(1)
+	for _, e := range edges {
+		edge := e.(map[string]interface{})
+		from, to := edge["from"].(int), edge["to"].(int)
+		if from < 0 || from > 4 || to < 0 || to > 4 {
+			t.Errorf("edge %v refers to an unregistered node", edge)
+		}
+		if from == to {
+			t.Errorf("edge %v is a self-loop", edge)
+		}
+	}
+	if again := generate(1); !reflect.DeepEqual(edges, again) {
+		t.Errorf("runs with the same seed differ:\n%v\n%v", edges, again)
+	}
+}
+
+func TestEdgeErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{"e":{"$edge":{"from":"nodes","to":"nodes"}}}`, `$edge: pool "nodes" is empty`},
+		{`{"a":{"$register":{"pool":"p","val":1}},"e":{"$edge":{"from":"p","to":"q"}}}`, `$edge: pool "q" is empty`},
+		{`{"a":{"$register":{"pool":"p","val":1}},"e":{"$edge":{"from":"p","to":"p","allowSelf":false}}}`, `$edge: pool "p" has no id other than 1`},
+		{`{"a":{"$register":{"pool":"p","val":1}},"e":{"$edge":{"from":"p","to":"p","allowSelf":"no"}}}`, "allowSelf for $edge must be a boolean"},
+		{`{"e":{"$edge":"nodes"}}`, "$edge requires a {from, to} object"},
+		{`{"a":{"$register":{"val":1}}}`, "missing pool for $register"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, tt.template, tt.want)
+	}
+}
+
+func TestPadIndex(t *testing.T) {
+	tests := []struct {
+		width int
+		index int
+		want  string
+	}{
+		{4, 7, "0007"},
+		{4, 0, "0000"},
+		{1, 7, "7"},
+		{2, 123, "123"}, // indices wider than width keep all digits
+	}
+	for _, tt := range tests {
+		records := generateRecords(t, `{"n":{"$padIndex":{"width":`+strconv.Itoa(tt.width)+`}}}`, tt.index+1, 1)
+		if got := field(records, "n")[tt.index]; got != tt.want {
+			t.Errorf("index %d with width %d: got %v, want %q", tt.index, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestPadIndexErrors(t *testing.T) {
+	for _, params := range []string{`{"width":0}`, `{"width":-2}`, `{"width":"wide"}`} {
+		assertGenerateError(t, `{"n":{"$padIndex":`+params+`}}`, "$padIndex requires a positive width")
+	}
+	assertGenerateError(t, `{"n":{"$padIndex":4}}`, "$padIndex requires a {width} object")
+}
+
+func TestExcept(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  string
+		allowed map[interface{}]bool
+	}{
+		{"int", `{"value":{"$int":{"min":1,"max":3}},"exclude":[2]}`, map[interface{}]bool{1: true, 3: true}},
+		{"string", `{"value":{"$choice":{"values":["a","b","c"]}},"exclude":["a","c"]}`, map[interface{}]bool{"b": true}},
+		{"nothing excluded", `{"value":{"$int":{"min":1,"max":2}},"exclude":[]}`, map[interface{}]bool{1: true, 2: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := `{"v":{"$except":` + tt.params + `}}`
+			for _, v := range field(generateRecords(t, template, 100, 1), "v") {
+				if !tt.allowed[v] {
+					t.Errorf("generated %v (%T), want one of %v", v, v, tt.allowed)
+				}
+			}
+			assertDeterministic(t, template, 10)
+		})
+	}
+}
+
+func TestExceptErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"value":{"$int":{"min":1,"max":2}},"exclude":[1,2]}`, "$except: no allowed value after 100 attempts"},
+		{`{"value":1,"exclude":2}`, "$except requires an exclude list"},
+		{`[1]`, "$except requires a {value, exclude} object"},
+		{`{"value":1}`, "missing exclude for $except"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"v":{"$except":`+tt.params+`}}`, tt.want)
+	}
+}
+
+func TestFreqStr(t *testing.T) {
+	template := `{"s":{"$freqStr":{"length":100,"freq":{"e":5,"t":3,"a":2,"z":0}}}}`
+	counts := make(map[rune]int)
+	total := 0
+	for _, v := range field(generateRecords(t, template, 200, 1), "s") {
+		s := v.(string)
+		if len(s) != 100 {
+			t.Fatalf("got a string of length %d, want 100", len(s))
+		}
+		for _, c := range s {
+			counts[c]++
+			total++
+		}
+	}
+	// The weights are normalized, so e, t, and a make up 50%, 30%, and 20%
+	for c, want := range map[rune]float64{'e': 0.5, 't': 0.3, 'a': 0.2, 'z': 0} {
+		if got := float64(counts[c]) / float64(total); got < want-0.02 || got > want+0.02 {
+			t.Errorf("%q makes up %.3f of the characters, want about %.2f", c, got, want)
+		}
+	}
+	assertDeterministic(t, template, 5)
+}
+
+func TestFreqStrErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"length":5,"freq":{}}`, "$freqStr requires a non-empty freq object"},
+		{`{"length":5,"freq":["a"]}`, "$freqStr requires a non-empty freq object"},
+		{`{"length":5,"freq":{"a":-1}}`, `invalid frequency for "a" in $freqStr`},
+		{`{"length":5,"freq":{"a":"often"}}`, `invalid frequency for "a" in $freqStr`},
+		{`{"length":5,"freq":{"a":0}}`, "$freqStr frequencies must sum to a positive value"},
+		{`"abc"`, "$freqStr requires a {length, freq} object"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"s":{"$freqStr":`+tt.params+`}}`, tt.want)
+	}
+}
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max string
+		wantMin  int
+		wantMax  int
+		wantErr  string
+	}{
+		{"min greater than max", "10", "5", 0, 0, "$int: max (5) must be >= min (10)"},
+		{"min equals max", "7", "7", 7, 7, ""},
+		{"min less than max", "-3", "3", -3, 3, ""},
+		{"full range", "-9223372036854775808", "9223372036854775807", math.MinInt, math.MaxInt, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := `{"n":{"$int":{"min":` + tt.min + `,"max":` + tt.max + `}}}`
+			if tt.wantErr != "" {
+				assertGenerateError(t, template, tt.wantErr)
+				return
+			}
+			seen := make(map[int]bool)
+			for _, v := range field(generateRecords(t, template, 200, 1), "n") {
+				n := v.(int)
+				if n < tt.wantMin || n > tt.wantMax {
+					t.Errorf("got %d, want %d to %d", n, tt.wantMin, tt.wantMax)
+				}
+				seen[n] = true
+			}
+			if tt.wantMin == tt.wantMax && len(seen) != 1 {
+				t.Errorf("got %d distinct values, want the constant %d", len(seen), tt.wantMin)
+			}
+			if tt.wantMax-tt.wantMin == 6 && len(seen) != 7 {
+				t.Errorf("got %d distinct values, want all 7", len(seen))
+			}
+			assertDeterministic(t, template, 5)
+		})
+	}
+}
+
+func TestArrLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		len      string
+		min, max int
+	}{
+		{"fixed", `3`, 3, 3},
+		{"zero", `0`, 0, 0},
+		{"range", `{"min":0,"max":3}`, 0, 3},
+		{"constant range", `{"min":2,"max":2}`, 2, 2},
+		{"generated", `{"$int":{"min":1,"max":2}}`, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := `{"a":{"$arr":{"len":` + tt.len + `,"val":"$digit"}}}`
+			seen := make(map[int]bool)
+			for _, v := range field(generateRecords(t, template, 200, 1), "a") {
+				n := len(v.([]interface{}))
+				if n < tt.min || n > tt.max {
+					t.Errorf("got length %d, want %d to %d", n, tt.min, tt.max)
+				}
+				seen[n] = true
+			}
+			// Over 200 records, every length in the range comes up
+			if len(seen) != tt.max-tt.min+1 {
+				t.Errorf("got lengths %v, want all of %d to %d", seen, tt.min, tt.max)
+			}
+			assertDeterministic(t, template, 5)
+		})
+	}
+}
+
+func TestArrErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"len":{"min":3,"max":1},"val":1}`, "$arr len: max (1) must be >= min (3)"},
+		{`{"len":-1,"val":1}`, "$arr: len must not be negative, got -1"},
+		{`{"len":"long","val":1}`, "invalid len value for $arr"},
+		{`{"len":2}`, "missing val for $arr"},
+		{`{"len":11,"val":"$digit","unique":true}`, "$arr: could not generate 11 unique values after 100 attempts"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"a":{"$arr":`+tt.params+`}}`, tt.want)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  string
+		pattern string
+	}{
+		{"generated", `{"sep":"-","values":["$u8","$u8"]}`, `^\d{1,3}-\d{1,3}$`},
+		{"literals", `{"sep":",","values":["a",1,true]}`, `^a,1,true$`},
+		{"empty separator", `{"sep":"","values":["x",{"$int":{"min":5,"max":5}}]}`, `^x5$`},
+		{"prefix separator", `{"sep":"$","values":["a","b"]}`, `^a\$b$`},
+		{"no values", `{"sep":",","values":[]}`, `^$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := `{"s":{"$join":` + tt.params + `}}`
+			re := regexp.MustCompile(tt.pattern)
+			for _, v := range field(generateRecords(t, template, 20, 1), "s") {
+				if !re.MatchString(v.(string)) {
+					t.Errorf("got %q, want a match for %s", v, tt.pattern)
+				}
+			}
+			assertDeterministic(t, template, 5)
+		})
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"sep":1,"values":["a"]}`, "sep for $join must be a string"},
+		{`{"sep":",","values":"a"}`, "values for $join must be a list"},
+		{`{"sep":",","values":[{"a":1}]}`, "$join requires a string value, got map[string]interface {}"},
+		{`{"sep":",","values":[null]}`, "$join requires a string value, got <nil>"},
+		{`["a","b"]`, "$join requires a {sep, values} object"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"s":{"$join":`+tt.params+`}}`, tt.want)
+	}
+}
+
+func TestConst(t *testing.T) {
+	// Values are written with sorted keys, as they are marshaled
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"generator name", `"$int"`},
+		{"generator object", `{"$int":{"max":2,"min":1}}`},
+		{"prefixed keys", `{"$u8":"$u8","$weird":1}`},
+		{"nested", `{"a":{"$ref:x":"$$","b":["$u8",{"$bool":null}]}}`},
+		{"list", `["$i",["$count",{"$seq":null}]]`},
+		{"number", `12345678901234567890`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := generateRecords(t, `{"c":{"$const":`+tt.value+`}}`, 2, 1)
+			for _, v := range field(records, "c") {
+				got, err := json.Marshal(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.value {
+					t.Errorf("got %s, want %s verbatim", got, tt.value)
+				}
+			}
+		})
+	}
+}
+
+func TestHex(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  string
+		pattern string
+	}{
+		{"sha256", `{"len":64}`, `^[0-9a-f]{64}$`},
+		{"uppercase", `{"len":40,"uppercase":true}`, `^[0-9A-F]{40}$`},
+		{"lowercase", `{"len":8,"uppercase":false}`, `^[0-9a-f]{8}$`},
+		{"empty", `{"len":0}`, `^$`},
+		{"generated len", `{"len":{"$int":{"min":4,"max":6}}}`, `^[0-9a-f]{4,6}$`},
+		{"len range", `{"len":{"min":1,"max":3}}`, `^[0-9a-f]{1,3}$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := `{"h":{"$hex":` + tt.params + `}}`
+			re := regexp.MustCompile(tt.pattern)
+			for _, v := range field(generateRecords(t, template, 20, 1), "h") {
+				if s, ok := v.(string); !ok || !re.MatchString(s) {
+					t.Errorf("got %#v, want a string matching %s", v, tt.pattern)
+				}
+			}
+			assertDeterministic(t, template, 5)
+		})
+	}
+}
+
+func TestHexErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`64`, "$hex requires a {len} object"},
+		{`{"len":-1}`, "$hex: len must not be negative, got -1"},
+		{`{"len":"long"}`, "invalid len value for $hex"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"h":{"$hex":`+tt.params+`}}`, tt.want)
+	}
+}
+
+func TestIncr(t *testing.T) {
+	template := `{"a":"$incr","b":{"$nullable":{"value":"$incr"}},"c":{"$arr":{"len":2,"val":"$incr"}},"d":{"e":"$incr"}}`
+	records := generateRecords(t, template, 20, 1)
+	next := 0 // the next value of b, which only advances when b is generated
+	for z, record := range records {
+		r := record.(map[string]interface{})
+		if r["a"] != z {
+			t.Errorf("record %d: a is %v, want %d", z, r["a"], z)
+		}
+		if c := r["c"].([]interface{}); c[0] != z || c[1] != z {
+			t.Errorf("record %d: c is %v, want every element %d", z, c, z)
+		}
+		if e := r["d"].(map[string]interface{})["e"]; e != z {
+			t.Errorf("record %d: d.e is %v, want %d", z, e, z)
+		}
+		if r["b"] != nil {
+			if r["b"] != next {
+				t.Errorf("record %d: b is %v, want %d", z, r["b"], next)
+			}
+			next++
+		}
+	}
+	if next == 0 || next == len(records) {
+		t.Errorf("b was generated in %d of %d records, want its counter to diverge from a", next, len(records))
+	}
+	assertDeterministic(t, template, 5)
+}
+
+func TestConvertIntegerKinds(t *testing.T) {
+	values := []interface{}{int(7), int8(7), int16(7), int32(7), int64(7), uint(7), uint8(7), uint16(7), uint32(7), uint64(7)}
+	for _, v := range values {
+		if n, err := convertToInt(v); err != nil || n != 7 {
+			t.Errorf("convertToInt(%T) = %d, %v; want 7", v, n, err)
+		}
+		if f, ok := convertToFloat(v); !ok || f != 7 {
+			t.Errorf("convertToFloat(%T) = %v, %v; want 7", v, f, ok)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// latLng returns a random {lat, lng} object, within the optional "bbox"
+// [minLat, minLng, maxLat, maxLng] of params and rounded to its optional
+// "precision".
+func (g *Generator) latLng(params interface{}) (interface{}, error) {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok && params != nil {
+		return nil, errors.New("$latlng accepts an optional {bbox, precision} object")
+	}
+
+	bbox := [4]float64{-90, -180, 90, 180}
+	if v, exists := paramsMap["bbox"]; exists {
+		list, ok := v.([]interface{})
+		if !ok || len(list) != 4 {
+			return nil, errors.New("bbox for $latlng must be a list of [minLat, minLng, maxLat, maxLng]")
+		}
+		for z, elem := range list {
+			if bbox[z], ok = convertToFloat(elem); !ok {
+				return nil, fmt.Errorf("bbox for $latlng has a non-numeric value %v", elem)
+			}
+		}
+		minLat, minLng, maxLat, maxLng := bbox[0], bbox[1], bbox[2], bbox[3]
+		if minLat < -90 || maxLat > 90 || minLng < -180 || maxLng > 180 {
+			return nil, errors.New("$latlng: bbox must lie within latitudes [-90, 90] and longitudes [-180, 180]")
+		}
+		if minLat > maxLat || minLng > maxLng {
+			return nil, fmt.Errorf("$latlng: bbox minimums must not exceed maximums, got %v", bbox)
+		}
+	}
+
+	lat := bbox[0] + g.rng.Float64()*(bbox[2]-bbox[0])
+	lng := bbox[1] + g.rng.Float64()*(bbox[3]-bbox[1])
+	if v, exists := paramsMap["precision"]; exists {
+		precision, err := convertToInt(v)
+		if err != nil || precision < 0 {
+			return nil, errors.New("invalid precision value for $latlng")
+		}
+		lat, lng = roundTo(lat, precision), roundTo(lng, precision)
+	}
+	return map[string]interface{}{"lat": lat, "lng": lng}, nil
+}
@@ -0,0 +1,136 @@
+package generator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+var (
+	emailDomains    = []string{"example", "mail", "inbox", "post", "mailbox", "webmail"}
+	urlDomains      = []string{"example", "acme", "shop", "news", "cloud", "app", "media", "data"}
+	topLevelDomains = []string{"com", "net", "org", "io", "dev", "co"}
+)
+
+const lowerAlnum = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// email returns a random address with a lowercase alphanumeric local part.
+// The domain is random unless domain is non-empty.
+func (g *Generator) email(domain string) string {
+	var sb strings.Builder
+	for n := 6 + g.rng.IntN(7); n > 0; n-- {
+		sb.WriteByte(lowerAlnum[g.rng.IntN(len(lowerAlnum))])
+	}
+	sb.WriteByte('@')
+	if domain == "" {
+		domain = emailDomains[g.rng.IntN(len(emailDomains))] + "." + topLevelDomains[g.rng.IntN(len(topLevelDomains))]
+	}
+	sb.WriteString(domain)
+	return sb.String()
+}
+
+// ipv4 returns a random IPv4 address, within cidr if it is non-empty.
+func (g *Generator) ipv4(cidr string) (string, error) {
+	addr := g.rng.Uint32()
+	if cidr != "" {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil || !prefix.Addr().Is4() {
+			return "", fmt.Errorf("invalid IPv4 CIDR %q", cidr)
+		}
+		base := prefix.Masked().Addr().As4()
+		hostMask := uint32(1)<<(32-prefix.Bits()) - 1
+		addr = binary.BigEndian.Uint32(base[:]) | addr&hostMask
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], addr)
+	return netip.AddrFrom4(b).String(), nil
+}
+
+// ipv6 returns a random IPv6 address in canonical compressed form.
+func (g *Generator) ipv6() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], g.rng.Uint64())
+	binary.BigEndian.PutUint64(b[8:], g.rng.Uint64())
+	return netip.AddrFrom16(b).String()
+}
+
+// url returns a random URL with the given scheme, or http or https if
+// scheme is empty, optionally with a path and query parameters.
+func (g *Generator) url(scheme string, withPath, withQuery bool) string {
+	if scheme == "" {
+		scheme = []string{"http", "https"}[g.rng.IntN(2)]
+	}
+	u := url.URL{
+		Scheme: scheme,
+		Host:   urlDomains[g.rng.IntN(len(urlDomains))] + "." + topLevelDomains[g.rng.IntN(len(topLevelDomains))],
+	}
+	if g.rng.IntN(2) == 0 {
+		u.Host = "www." + u.Host
+	}
+	if withPath {
+		for n := 1 + g.rng.IntN(3); n > 0; n-- {
+			u.Path += "/" + loremWords[g.rng.IntN(len(loremWords))]
+		}
+	}
+	if withQuery {
+		query := url.Values{}
+		for n := 1 + g.rng.IntN(3); n > 0; n-- {
+			query.Set(loremWords[g.rng.IntN(len(loremWords))], g.randomString(lowerAlnum, 1+g.rng.IntN(8)))
+		}
+		u.RawQuery = query.Encode()
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+	return u.String()
+}
+
+// userAgents are User-Agent strings of common browsers and crawlers, by
+// device type.
+var userAgents = []struct {
+	kind   string
+	agents []string
+}{
+	{"desktop", []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.80",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+	}},
+	{"mobile", []string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (iPad; CPU OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.113 Mobile Safari/537.36",
+		"Mozilla/5.0 (Linux; Android 14; SM-S921B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36",
+		"Mozilla/5.0 (Android 14; Mobile; rv:125.0) Gecko/125.0 Firefox/125.0",
+	}},
+	{"bot", []string{
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+		"DuckDuckBot/1.1; (+http://duckduckgo.com/duckduckbot.html)",
+		"Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)",
+		"curl/8.7.1",
+	}},
+}
+
+// userAgent returns a User-Agent string of the given type ("desktop",
+// "mobile", or "bot"), or of any type if kind is empty.
+func (g *Generator) userAgent(kind string) (string, error) {
+	var pool []string
+	var kinds []string
+	for _, group := range userAgents {
+		kinds = append(kinds, group.kind)
+		if kind == "" || kind == group.kind {
+			pool = append(pool, group.agents...)
+		}
+	}
+	if len(pool) == 0 {
+		return "", fmt.Errorf("unknown type %q (supported: %s)", kind, strings.Join(kinds, ", "))
+	}
+	return pool[g.rng.IntN(len(pool))], nil
+}
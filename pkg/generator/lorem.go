@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+var loremWords = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit",
+	"sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore",
+	"magna", "aliqua", "enim", "ad", "minim", "veniam", "quis", "nostrud",
+	"exercitation", "ullamco", "laboris", "nisi", "aliquip", "ex", "ea", "commodo",
+	"consequat", "duis", "aute", "irure", "in", "reprehenderit", "voluptate",
+	"velit", "esse", "cillum", "fugiat", "nulla", "pariatur", "excepteur", "sint",
+	"occaecat", "cupidatat", "non", "proident", "sunt", "culpa", "qui", "officia",
+	"deserunt", "mollit", "anim", "id", "est", "laborum",
+}
+
+// lorem returns count words, sentences, or paragraphs of filler text.
+func (g *Generator) lorem(unit string, count int) (string, error) {
+	switch unit {
+	case "words":
+		return g.loremWords(count), nil
+	case "sentences":
+		return g.loremSentences(count), nil
+	case "paragraphs":
+		paragraphs := make([]string, count)
+		for z := range paragraphs {
+			paragraphs[z] = g.loremSentences(3 + g.rng.IntN(4))
+		}
+		return strings.Join(paragraphs, "\n\n"), nil
+	default:
+		return "", fmt.Errorf("unknown unit %q (supported: words, sentences, paragraphs)", unit)
+	}
+}
+
+func (g *Generator) loremWords(count int) string {
+	words := make([]string, count)
+	for z := range words {
+		words[z] = loremWords[g.rng.IntN(len(loremWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+// loremSentences returns count capitalized sentences of 4 to 12 words.
+func (g *Generator) loremSentences(count int) string {
+	sentences := make([]string, count)
+	for z := range sentences {
+		words := g.loremWords(4 + g.rng.IntN(9))
+		sentences[z] = strings.ToUpper(words[:1]) + words[1:] + "."
+	}
+	return strings.Join(sentences, " ")
+}
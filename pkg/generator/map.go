@@ -0,0 +1,74 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// mapItems resolves source to a list and resolves template once for each
+// element, with the element bound to the variable named by "as" ("$item" by
+// default). The binding is restored afterwards, so $map calls can be nested.
+func (g *Generator) mapItems(params interface{}, i int) (interface{}, error) {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("$map requires a {source, template} object")
+	}
+	local, err := g.mapVariable(paramsMap)
+	if err != nil {
+		return nil, err
+	}
+	template, exists := paramsMap["template"]
+	if !exists {
+		return nil, errors.New("missing template for $map")
+	}
+	resolvedSource, err := g.Generate(i, paramsMap["source"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source for $map: %w", err)
+	}
+	source, ok := resolvedSource.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("source for $map must resolve to a list, got %T", resolvedSource)
+	}
+
+	outer, shadowed := g.locals[local]
+	defer g.restoreLocal(local, outer, shadowed)
+	result := make([]interface{}, len(source))
+	for z, item := range source {
+		if err := g.ctxErr(); err != nil {
+			return nil, err
+		}
+		g.locals[local] = item
+		if result[z], err = g.Generate(i, template); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// mapVariable returns the name of the variable a $map binds, without the
+// prefix.
+func (g *Generator) mapVariable(paramsMap map[string]interface{}) (string, error) {
+	as := g.prefix + "item"
+	if v, exists := paramsMap["as"]; exists {
+		var ok bool
+		if as, ok = v.(string); !ok || !strings.HasPrefix(as, g.prefix) || as == g.prefix {
+			return "", fmt.Errorf("as for $map must be a variable name starting with %q", g.prefix)
+		}
+	}
+	name := strings.TrimPrefix(as, g.prefix)
+	if isPredefinedVar(name) || strings.HasPrefix(name, "ref:") {
+		return "", fmt.Errorf("$map cannot bind %q, which names a generator", as)
+	}
+	return name, nil
+}
+
+// restoreLocal undoes the binding of a local variable, putting back the
+// value of an enclosing binding it shadowed.
+func (g *Generator) restoreLocal(name string, outer interface{}, shadowed bool) {
+	if shadowed {
+		g.locals[name] = outer
+	} else {
+		delete(g.locals, name)
+	}
+}
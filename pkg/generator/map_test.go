@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     interface{}
+	}{
+		{
+			name:     "wraps each element",
+			template: `{"$map":{"source":[1,2,3],"template":{"value":"$item"}}}`,
+			want: []interface{}{
+				map[string]interface{}{"value": int64(1)},
+				map[string]interface{}{"value": int64(2)},
+				map[string]interface{}{"value": int64(3)},
+			},
+		},
+		{
+			name:     "custom name",
+			template: `{"$map":{"source":["a","b"],"as":"$x","template":["$x","$x"]}}`,
+			want:     []interface{}{[]interface{}{"a", "a"}, []interface{}{"b", "b"}},
+		},
+		{
+			name:     "nested",
+			template: `{"$map":{"source":[1,2],"as":"$row","template":{"$map":{"source":["a","b"],"as":"$col","template":{"r":"$row","c":"$col"}}}}}`,
+			want: []interface{}{
+				[]interface{}{map[string]interface{}{"r": int64(1), "c": "a"}, map[string]interface{}{"r": int64(1), "c": "b"}},
+				[]interface{}{map[string]interface{}{"r": int64(2), "c": "a"}, map[string]interface{}{"r": int64(2), "c": "b"}},
+			},
+		},
+		{
+			name:     "inner shadows outer",
+			template: `{"$map":{"source":[1,2],"template":{"outer":"$item","inner":{"$map":{"source":["a"],"template":"$item"}},"after":"$item"}}}`,
+			want: []interface{}{
+				map[string]interface{}{"outer": int64(1), "inner": []interface{}{"a"}, "after": int64(1)},
+				map[string]interface{}{"outer": int64(2), "inner": []interface{}{"a"}, "after": int64(2)},
+			},
+		},
+		{
+			name:     "empty source",
+			template: `{"$map":{"source":[],"template":"$item"}}`,
+			want:     []interface{}{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := generateRecords(t, `{"m":`+tt.template+`}`, 1, 1)
+			if got := field(records, "m")[0]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapGeneratedSource(t *testing.T) {
+	template := `{"m":{"$map":{"source":{"$arr":{"len":5,"val":{"$int":{"min":1,"max":9}}}},"template":{"value":"$item"}}}}`
+	for _, v := range field(generateRecords(t, template, 10, 1), "m") {
+		list := v.([]interface{})
+		if len(list) != 5 {
+			t.Fatalf("got %d elements, want 5", len(list))
+		}
+		for _, elem := range list {
+			if n := elem.(map[string]interface{})["value"].(int); n < 1 || n > 9 {
+				t.Errorf("value %d out of range", n)
+			}
+		}
+	}
+	assertDeterministic(t, template, 5)
+}
+
+func TestMapErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{"m":{"$map":{"source":1,"template":"$item"}}}`, "source for $map must resolve to a list"},
+		{`{"m":{"$map":{"source":[1],"as":"x","template":"$x"}}}`, `as for $map must be a variable name starting with "$"`},
+		{`{"m":{"$map":{"source":[1],"as":"$u8","template":"$u8"}}}`, `$map cannot bind "$u8", which names a generator`},
+		{`{"m":{"$map":{"source":[1],"template":"$item"}},"n":"$item"}`, `undefined variable: "$item"`},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, tt.template, tt.want)
+	}
+}
@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// quantity describes a physical quantity supported by $measurement. Values
+// are converted from the base unit with the per-unit functions, and the
+// default range (in the base unit) is used when min or max is omitted.
+type quantity struct {
+	baseUnit   string
+	defaultMin float64
+	defaultMax float64
+	units      map[string]func(base float64) float64
+}
+
+var quantities = map[string]quantity{
+	"temperature": {
+		baseUnit:   "C",
+		defaultMin: -10,
+		defaultMax: 40,
+		units: map[string]func(float64) float64{
+			"C": func(v float64) float64 { return v },
+			"F": func(v float64) float64 { return v*9/5 + 32 },
+			"K": func(v float64) float64 { return v + 273.15 },
+		},
+	},
+	"distance": {
+		baseUnit:   "m",
+		defaultMin: 0,
+		defaultMax: 1000,
+		units: map[string]func(float64) float64{
+			"m":  func(v float64) float64 { return v },
+			"km": func(v float64) float64 { return v / 1000 },
+			"mi": func(v float64) float64 { return v / 1609.344 },
+			"ft": func(v float64) float64 { return v / 0.3048 },
+		},
+	},
+	"weight": {
+		baseUnit:   "kg",
+		defaultMin: 0,
+		defaultMax: 100,
+		units: map[string]func(float64) float64{
+			"kg": func(v float64) float64 { return v },
+			"g":  func(v float64) float64 { return v * 1000 },
+			"lb": func(v float64) float64 { return v / 0.45359237 },
+			"oz": func(v float64) float64 { return v / 0.028349523125 },
+		},
+	},
+}
+
+func (g *Generator) measurement(params interface{}) (interface{}, error) {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("$measurement requires a {quantity, unit} object")
+	}
+
+	name, _ := paramsMap["quantity"].(string)
+	q, ok := quantities[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown quantity %q for $measurement (supported: %s)", name, strings.Join(sortedKeys(quantities), ", "))
+	}
+
+	unit := q.baseUnit
+	if u, exists := paramsMap["unit"]; exists {
+		unit, _ = u.(string)
+	}
+	convert, ok := q.units[unit]
+	if !ok {
+		return nil, fmt.Errorf("unit %q is not valid for %s (supported: %s)", unit, name, strings.Join(sortedKeys(q.units), ", "))
+	}
+
+	min, max := convert(q.defaultMin), convert(q.defaultMax)
+	if v, exists := paramsMap["min"]; exists {
+		if min, ok = convertToFloat(v); !ok {
+			return nil, fmt.Errorf("invalid min value for $measurement")
+		}
+	}
+	if v, exists := paramsMap["max"]; exists {
+		if max, ok = convertToFloat(v); !ok {
+			return nil, fmt.Errorf("invalid max value for $measurement")
+		}
+	}
+	if max < min {
+		return nil, fmt.Errorf("$measurement: max (%v) must be >= min (%v)", max, min)
+	}
+
+	value := min + g.rng.Float64()*(max-min)
+	if p, exists := paramsMap["precision"]; exists {
+		precision, err := convertToInt(p)
+		if err != nil || precision < 0 {
+			return nil, fmt.Errorf("invalid precision value for $measurement")
+		}
+		value = roundTo(value, precision)
+	}
+
+	if asObject, _ := paramsMap["object"].(bool); asObject {
+		return map[string]interface{}{"value": value, "unit": unit}, nil
+	}
+	return value, nil
+}
+
+// roundTo rounds v to the given number of decimal places.
+func roundTo(v float64, precision int) float64 {
+	scale := math.Pow(10, float64(precision))
+	return math.Round(v*scale) / scale
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeasurement(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   string
+		min, max float64
+		unit     string // unit of the object form, empty for a plain number
+	}{
+		{"celsius", `{"quantity":"temperature","unit":"C","min":-10,"max":40,"precision":1}`, -10, 40, ""},
+		{"default unit and range", `{"quantity":"weight"}`, 0, 100, ""},
+		{"default range converted", `{"quantity":"temperature","unit":"F"}`, 14, 104, ""},
+		{"object", `{"quantity":"distance","unit":"km","min":1,"max":2,"object":true}`, 1, 2, "km"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := `{"m":{"$measurement":` + tt.params + `}}`
+			for _, v := range field(generateRecords(t, template, 50, 1), "m") {
+				if tt.unit != "" {
+					obj := v.(map[string]interface{})
+					if obj["unit"] != tt.unit {
+						t.Errorf("unit %v, want %s", obj["unit"], tt.unit)
+					}
+					v = obj["value"]
+				}
+				f := v.(float64)
+				if f < tt.min || f > tt.max {
+					t.Errorf("value %v out of range [%v, %v]", f, tt.min, tt.max)
+				}
+			}
+			assertDeterministic(t, template, 5)
+		})
+	}
+}
+
+func TestMeasurementPrecision(t *testing.T) {
+	template := `{"m":{"$measurement":{"quantity":"temperature","precision":1}}}`
+	for _, v := range field(generateRecords(t, template, 50, 1), "m") {
+		f := v.(float64)
+		if math.Abs(f*10-math.Round(f*10)) > 1e-9 {
+			t.Errorf("value %v has more than one decimal place", f)
+		}
+	}
+}
+
+func TestMeasurementErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`"temperature"`, "$measurement requires a {quantity} object"},
+		{`{"quantity":"speed"}`, `unknown quantity "speed" for $measurement (supported: distance, temperature, weight)`},
+		{`{"quantity":"temperature","unit":"kg"}`, `unit "kg" is not valid for temperature (supported: C, F, K)`},
+		{`{"quantity":"weight","min":5,"max":1}`, "$measurement: max (1) must be >= min (5)"},
+		{`{"quantity":"weight","min":"low"}`, "invalid min value for $measurement"},
+		{`{"quantity":"weight","precision":-1}`, "invalid precision value for $measurement"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"m":{"$measurement":`+tt.params+`}}`, tt.want)
+	}
+}
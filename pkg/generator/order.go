@@ -0,0 +1,147 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// OrderedMap is a generated object whose keys marshal in the order they
+// appear in the template. Objects are generated as OrderedMaps when the
+// Generator is created with WithPreserveOrder.
+type OrderedMap struct {
+	Keys   []string
+	Values map[string]interface{}
+}
+
+// MarshalJSON encodes the object with its keys in order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for z, key := range m.Keys {
+		if z > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.Values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// asObject returns the fields of a generated object, whether or not it
+// preserves key order.
+func asObject(v interface{}) (map[string]interface{}, bool) {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		return o, true
+	case *OrderedMap:
+		return o.Values, true
+	default:
+		return nil, false
+	}
+}
+
+// WithPreserveOrder makes generated objects keep the key order of the
+// template they come from, as OrderedMaps. Only templates parsed with the
+// Generator's Parse method carry their key order. Fields are still
+// generated in sorted key order, so $ref and seeded output are unaffected.
+func WithPreserveOrder() Option {
+	return func(g *Generator) {
+		g.preserveOrder = true
+	}
+}
+
+// Parse decodes a JSON template like ParseTemplate, and also records the
+// key order of each object. The order is used by $split cohorts given as an
+// object and, with WithPreserveOrder, by the objects generated from it.
+func (g *Generator) Parse(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	template, err := g.decodeOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return template, nil
+}
+
+func (g *Generator) decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := make(map[string]interface{})
+		var keys []string
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			value, err := g.decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := obj[key]; !exists {
+				keys = append(keys, key)
+			}
+			obj[key] = value
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		g.keyOrders[mapID(obj)] = orderedKeys{obj: obj, keys: keys}
+		return obj, nil
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			elem, err := g.decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, elem)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return list, nil
+	default:
+		return tok, nil
+	}
+}
+
+// orderedKeys is the key order of a parsed template object. It holds on to
+// the object so that its address is not reused while the order is recorded.
+type orderedKeys struct {
+	obj  map[string]interface{}
+	keys []string
+}
+
+// keyOrder returns the template order of the keys of obj, if it was
+// recorded by Parse.
+func (g *Generator) keyOrder(obj map[string]interface{}) ([]string, bool) {
+	entry, ok := g.keyOrders[mapID(obj)]
+	return entry.keys, ok
+}
+
+// mapID identifies a template object, which stays alive and unchanged for
+// as long as the template is used.
+func mapID(obj map[string]interface{}) uintptr {
+	return reflect.ValueOf(obj).Pointer()
+}
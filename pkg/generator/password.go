@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// passwordClasses are the character classes of $password, in the order
+// their minimums are drawn.
+var passwordClasses = []struct {
+	name    string
+	charset string
+}{
+	{"upper", "ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+	{"lower", "abcdefghijklmnopqrstuvwxyz"},
+	{"digits", "0123456789"},
+	{"symbols", "!#$%&*+-=?@^_~"},
+}
+
+// password returns a password of len characters (12 by default). Each class
+// param is true to require at least one character of the class, a count to
+// require at least that many, or false to leave the class out; classes that
+// are not given are required once. The remaining characters are drawn from
+// all included classes, and the result is shuffled.
+func (g *Generator) password(params interface{}, i int) (string, error) {
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok && params != nil {
+		return "", errors.New("$password accepts an optional {len, upper, lower, digits, symbols} object")
+	}
+	length := 12
+	if v, exists := paramsMap["len"]; exists {
+		var err error
+		if length, err = g.length("$password", v, i); err != nil {
+			return "", err
+		}
+	}
+
+	var chars []rune
+	var pool string
+	required := 0
+	for _, class := range passwordClasses {
+		min := 1
+		if v, exists := paramsMap[class.name]; exists {
+			switch t := v.(type) {
+			case bool:
+				if !t {
+					continue
+				}
+			default:
+				n, err := convertToInt(v)
+				if err != nil || n < 0 {
+					return "", fmt.Errorf("%s for $password must be a boolean or a non-negative count", class.name)
+				}
+				min = n
+			}
+		}
+		pool += class.charset
+		required += min
+		chars = append(chars, []rune(g.randomString(class.charset, min))...)
+	}
+	if pool == "" {
+		return "", errors.New("$password: at least one character class must be included")
+	}
+	if required > length {
+		return "", fmt.Errorf("$password: the classes require %d characters, more than len %d", required, length)
+	}
+
+	chars = append(chars, []rune(g.randomString(pool, length-required))...)
+	g.rng.Shuffle(len(chars), func(a, b int) {
+		chars[a], chars[b] = chars[b], chars[a]
+	})
+	return string(chars), nil
+}
@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// locale holds the data used to build internally consistent fake people
+// for a single country.
+type locale struct {
+	firstNames []string // alternating male and female names
+	lastNames  []string
+	domains    []string
+	streets    []string
+	cities     []string
+	phone      func(r *rand.Rand) string
+	postcode   func(r *rand.Rand) string
+	street     func(r *rand.Rand, name string) string
+}
+
+var locales = map[string]locale{
+	"US": {
+		firstNames: []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "William", "Elizabeth"},
+		lastNames:  []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Wilson", "Moore"},
+		domains:    []string{"gmail.com", "yahoo.com", "outlook.com", "example.com"},
+		streets:    []string{"Main St", "Oak Ave", "Maple Dr", "Cedar Ln", "Elm St", "Washington Blvd"},
+		cities:     []string{"Springfield", "Riverside", "Franklin", "Greenville", "Fairview", "Madison"},
+		phone: func(r *rand.Rand) string {
+			return fmt.Sprintf("+1 (%d%s) %d%s-%s", r.IntN(8)+2, randomDigits(r, 2), r.IntN(8)+2, randomDigits(r, 2), randomDigits(r, 4))
+		},
+		postcode: func(r *rand.Rand) string {
+			return randomDigits(r, 5)
+		},
+		street: func(r *rand.Rand, name string) string {
+			return fmt.Sprintf("%d %s", r.IntN(9999)+1, name)
+		},
+	},
+	"GB": {
+		firstNames: []string{"Oliver", "Amelia", "George", "Isla", "Harry", "Ava", "Jack", "Emily", "Charlie", "Sophie"},
+		lastNames:  []string{"Smith", "Jones", "Taylor", "Brown", "Williams", "Wilson", "Evans", "Thomas", "Roberts", "Walker"},
+		domains:    []string{"gmail.com", "btinternet.com", "outlook.co.uk", "example.co.uk"},
+		streets:    []string{"High Street", "Station Road", "Church Lane", "Victoria Road", "Park Road", "Mill Lane"},
+		cities:     []string{"London", "Manchester", "Bristol", "Leeds", "Oxford", "York"},
+		phone: func(r *rand.Rand) string {
+			return fmt.Sprintf("+44 7%s %s", randomDigits(r, 3), randomDigits(r, 6))
+		},
+		postcode: func(r *rand.Rand) string {
+			return fmt.Sprintf("%s%d %d%s", randomUpper(r, 2), r.IntN(9)+1, r.IntN(10), randomUpper(r, 2))
+		},
+		street: func(r *rand.Rand, name string) string {
+			return fmt.Sprintf("%d %s", r.IntN(200)+1, name)
+		},
+	},
+	"JP": {
+		firstNames: []string{"Haruto", "Yui", "Sota", "Hina", "Yuto", "Aoi", "Riku", "Mei", "Kaito", "Sakura"},
+		lastNames:  []string{"Sato", "Suzuki", "Takahashi", "Tanaka", "Watanabe", "Ito", "Yamamoto", "Nakamura", "Kobayashi", "Kato"},
+		domains:    []string{"gmail.com", "yahoo.co.jp", "docomo.ne.jp", "example.jp"},
+		streets:    []string{"Ginza", "Shibuya", "Umeda", "Sakae", "Tenjin", "Sannomiya"},
+		cities:     []string{"Tokyo", "Osaka", "Nagoya", "Fukuoka", "Kobe", "Sapporo"},
+		phone: func(r *rand.Rand) string {
+			return fmt.Sprintf("+81 90-%s-%s", randomDigits(r, 4), randomDigits(r, 4))
+		},
+		postcode: func(r *rand.Rand) string {
+			return fmt.Sprintf("%s-%s", randomDigits(r, 3), randomDigits(r, 4))
+		},
+		street: func(r *rand.Rand, name string) string {
+			return fmt.Sprintf("%s %d-%d-%d", name, r.IntN(9)+1, r.IntN(30)+1, r.IntN(20)+1)
+		},
+	},
+}
+
+// lookupLocale returns the locale for the given country code, or an error
+// listing the supported codes.
+func lookupLocale(country string) (locale, error) {
+	loc, ok := locales[strings.ToUpper(country)]
+	if !ok {
+		return locale{}, fmt.Errorf("unsupported country %q (supported: %s)", country, strings.Join(sortedKeys(locales), ", "))
+	}
+	return loc, nil
+}
+
+// firstName picks a first name, restricted to the given gender ("male" or
+// "female") unless gender is empty.
+func (loc locale) firstName(r *rand.Rand, gender string) (string, error) {
+	switch gender {
+	case "":
+		return loc.firstNames[r.IntN(len(loc.firstNames))], nil
+	case "male", "female":
+		start := 0
+		if gender == "female" {
+			start = 1
+		}
+		n := (len(loc.firstNames) - start + 1) / 2
+		return loc.firstNames[start+2*r.IntN(n)], nil
+	default:
+		return "", fmt.Errorf("unsupported gender %q (supported: female, male)", gender)
+	}
+}
+
+// person builds a fake person whose email is derived from the name and whose
+// phone number and address belong to the same country.
+func (loc locale) person(r *rand.Rand, country string) map[string]interface{} {
+	first := loc.firstNames[r.IntN(len(loc.firstNames))]
+	last := loc.lastNames[r.IntN(len(loc.lastNames))]
+	domain := loc.domains[r.IntN(len(loc.domains))]
+
+	return map[string]interface{}{
+		"name":  first + " " + last,
+		"email": fmt.Sprintf("%s.%s@%s", strings.ToLower(first), strings.ToLower(last), domain),
+		"phone": loc.phone(r),
+		"address": map[string]interface{}{
+			"street":   loc.street(r, loc.streets[r.IntN(len(loc.streets))]),
+			"city":     loc.cities[r.IntN(len(loc.cities))],
+			"postcode": loc.postcode(r),
+			"country":  country,
+		},
+	}
+}
+
+func randomDigits(r *rand.Rand, n int) string {
+	var sb strings.Builder
+	for z := 0; z < n; z++ {
+		sb.WriteByte(byte('0' + r.IntN(10)))
+	}
+	return sb.String()
+}
+
+func randomUpper(r *rand.Rand, n int) string {
+	var sb strings.Builder
+	for z := 0; z < n; z++ {
+		sb.WriteByte(byte('A' + r.IntN(26)))
+	}
+	return sb.String()
+}
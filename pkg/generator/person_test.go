@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPerson(t *testing.T) {
+	tests := []struct {
+		country     string
+		phonePrefix string
+	}{
+		{"US", "+1 ("},
+		{"gb", "+44 7"},
+		{"JP", "+81 90-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.country, func(t *testing.T) {
+			template := `{"p":{"$person":{"country":"` + tt.country + `"}}}`
+			for _, record := range generateRecords(t, template, 20, 1) {
+				p := record.(map[string]interface{})["p"].(map[string]interface{})
+				name := strings.Fields(p["name"].(string))
+				email := p["email"].(string)
+				if len(name) != 2 || !strings.HasPrefix(email, strings.ToLower(name[0])+"."+strings.ToLower(name[1])+"@") {
+					t.Errorf("email %q is not derived from name %q", email, p["name"])
+				}
+				if phone := p["phone"].(string); !strings.HasPrefix(phone, tt.phonePrefix) {
+					t.Errorf("phone %q does not start with %q", phone, tt.phonePrefix)
+				}
+				address := p["address"].(map[string]interface{})
+				if address["country"] != strings.ToUpper(tt.country) {
+					t.Errorf("address country %v, want %s", address["country"], strings.ToUpper(tt.country))
+				}
+				for _, key := range []string{"street", "city", "postcode"} {
+					if s, _ := address[key].(string); s == "" {
+						t.Errorf("address has no %s: %v", key, address)
+					}
+				}
+			}
+			assertDeterministic(t, template, 5)
+		})
+	}
+}
+
+func TestPersonErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{"p":{"$person":{"country":"XX"}}}`, `unsupported country "XX" (supported: GB, JP, US)`},
+		{`{"p":{"$person":"US"}}`, "$person requires a {country} object"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, tt.template, tt.want)
+	}
+}
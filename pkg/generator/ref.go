@@ -0,0 +1,110 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// refFrame is an object whose fields are being generated, together with the
+// key of the field currently in progress and what is needed to generate its
+// remaining fields on demand.
+type refFrame struct {
+	obj          map[string]interface{}
+	key          string
+	node         *objectNode
+	states       []int             // state of each field of node
+	resolvedKeys map[string]string // template key to generated key, if the output preserves key order
+	i            int
+}
+
+// lookupRef resolves a dot-separated field path, such as "user.id" or
+// "events.0.id", against the record being generated. A field of an object
+// still being generated that has not had its turn yet is generated on
+// demand, so a path may refer to a field anywhere in the template as long as
+// its key is a literal. A path that leads back to the field being generated
+// is a reference cycle and an error.
+func (g *Generator) lookupRef(path string) (interface{}, error) {
+	if len(g.scope) == 0 {
+		return nil, errors.New("$ref can only be used inside an object")
+	}
+
+	var cur interface{} = g.scope[0].obj
+	level := 0 // index of cur in g.scope while cur is still being generated
+	for _, segment := range strings.Split(path, ".") {
+		if obj, ok := cur.(*OrderedMap); ok {
+			cur = obj.Values
+		}
+		switch c := cur.(type) {
+		case map[string]interface{}:
+			if v, exists := c[segment]; exists {
+				cur, level = v, -1
+				continue
+			}
+			if level < 0 {
+				return nil, fmt.Errorf("$ref: field %q does not exist", path)
+			}
+			frame := g.scope[level]
+			if frame.key == segment && level+1 < len(g.scope) {
+				level++
+				cur = g.scope[level].obj
+				continue
+			}
+			if z, ok := frame.node.literals[segment]; ok {
+				if frame.states[z] != fieldPending {
+					return nil, fmt.Errorf("$ref: reference cycle through field %q", path)
+				}
+				if err := g.resolveOnDemand(level, z); err != nil {
+					return nil, err
+				}
+				if cur, ok = c[segment]; !ok {
+					return nil, fmt.Errorf("$ref: field %q was omitted", path)
+				}
+				level = -1
+				continue
+			}
+			return nil, fmt.Errorf("$ref: field %q has not been generated", path)
+		case []interface{}:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(c) {
+				return nil, fmt.Errorf("$ref: invalid index %q in %q", segment, path)
+			}
+			cur, level = c[idx], -1
+		default:
+			return nil, fmt.Errorf("$ref: cannot descend into %T at %q in %q", cur, segment, path)
+		}
+	}
+	if level >= 0 {
+		return nil, fmt.Errorf("$ref: field %q refers to an enclosing object that is still being generated", path)
+	}
+	return cur, nil
+}
+
+// resolveOnDemand generates the z-th field of the object at g.scope[level]
+// ahead of its turn. The frames nested inside that object are set aside
+// meanwhile, so the field sees the same scope as when generated in turn, and
+// the key in progress is restored afterwards.
+func (g *Generator) resolveOnDemand(level, z int) error {
+	nested := append([]refFrame(nil), g.scope[level+1:]...)
+	key := g.scope[level].key
+	g.scope = g.scope[:level+1]
+	defer func() {
+		g.scope[level].key = key
+		g.scope = append(g.scope, nested...)
+	}()
+	if err := g.scope[level].node.resolveField(g, level, z); err != nil {
+		return fmt.Errorf("$ref: %w", err)
+	}
+	return nil
+}
+
+// fieldPath returns the dot-separated path of the field being generated,
+// which identifies the call site of a generator within the template.
+func (g *Generator) fieldPath() string {
+	keys := make([]string, len(g.scope))
+	for z, frame := range g.scope {
+		keys[z] = frame.key
+	}
+	return strings.Join(keys, ".")
+}
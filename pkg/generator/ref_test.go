@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRefResolvesFieldsOnDemand(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		check    func(record map[string]interface{}) bool
+	}{
+		{
+			name:     "later sibling",
+			template: `{"a":"$ref:b","b":"$u32"}`,
+			check: func(r map[string]interface{}) bool {
+				return r["a"] == r["b"]
+			},
+		},
+		{
+			name:     "generator form",
+			template: `{"dob":{"$date":{"min":"1990-01-01","max":"1990-01-01"}},"age":{"$age":{"birthdate":{"$ref":"dob"},"today":"2000-06-01"}}}`,
+			check: func(r map[string]interface{}) bool {
+				return r["dob"] == "1990-01-01" && r["age"] == 10
+			},
+		},
+		{
+			name:     "inside a literal array",
+			template: `{"user_id":"$u32","events":[{"user":"$ref:user_id"},{"user":"$ref:user_id"}]}`,
+			check: func(r map[string]interface{}) bool {
+				events := r["events"].([]interface{})
+				return events[0].(map[string]interface{})["user"] == r["user_id"] &&
+					events[1].(map[string]interface{})["user"] == r["user_id"]
+			},
+		},
+		{
+			name:     "nested path",
+			template: `{"a":"$ref:z.id","z":{"id":"$u32","copy":"$ref:z.id"}}`,
+			check: func(r map[string]interface{}) bool {
+				z := r["z"].(map[string]interface{})
+				return r["a"] == z["id"] && z["copy"] == z["id"]
+			},
+		},
+		{
+			name:     "chain",
+			template: `{"a":"$ref:b","b":"$ref:c","c":"$u32"}`,
+			check: func(r map[string]interface{}) bool {
+				return r["a"] == r["c"] && r["b"] == r["c"]
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, record := range generateRecords(t, tt.template, 5, 1) {
+				if !tt.check(record.(map[string]interface{})) {
+					got, _ := json.Marshal(record)
+					t.Errorf("unexpected record %s", got)
+				}
+			}
+		})
+	}
+}
+
+func TestRefIsDeterministic(t *testing.T) {
+	template := `{"a":"$ref:b","b":"$u32","c":"$u32"}`
+	first := generateRecords(t, template, 5, 7)
+	second := generateRecords(t, template, 5, 7)
+	for z := range first {
+		if first[z].(map[string]interface{})["c"] != second[z].(map[string]interface{})["c"] {
+			t.Fatalf("record %d differs between runs with the same seed", z)
+		}
+	}
+}
+
+func TestRefErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"cycle", `{"a":"$ref:b","b":"$ref:a"}`, `reference cycle through field "a"`},
+		{"self", `{"a":"$ref:a"}`, `reference cycle through field "a"`},
+		{"enclosing object", `{"a":{"x":"$ref:a"}}`, `refers to an enclosing object`},
+		{"missing field", `{"a":"$ref:b"}`, `field "b" has not been generated`},
+		{"omitted field", `{"a":"$ref:b","b":{"$option":{"value":1,"probability":0}}}`, `field "b" was omitted`},
+		{"bad index", `{"a":[1],"b":"$ref:a.3"}`, `invalid index "3"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tryGenerateRecords(tt.template, 1, 1)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// defaultMaxRepeat caps unbounded quantifiers such as * and + in $regex.
+const defaultMaxRepeat = 10
+
+// regexString returns a random string matching pattern. Unbounded
+// quantifiers repeat at most maxRepeat times beyond their minimum.
+func (g *Generator) regexString(pattern string, maxRepeat int) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	// Anchors match the position only, so they are dropped from the start
+	// and end of the pattern. Anywhere else they could rule out every
+	// string, as in "a^b", and writeRegex rejects them.
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	for len(subs) > 0 && isAnchor(subs[0]) {
+		subs = subs[1:]
+	}
+	for len(subs) > 0 && isAnchor(subs[len(subs)-1]) {
+		subs = subs[:len(subs)-1]
+	}
+	var sb strings.Builder
+	for _, sub := range subs {
+		if err := g.writeRegex(&sb, sub, maxRepeat); err != nil {
+			return "", fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+	}
+	return sb.String(), nil
+}
+
+// isAnchor reports whether re matches a line or text boundary.
+func isAnchor(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText:
+		return true
+	}
+	return false
+}
+
+func (g *Generator) writeRegex(sb *strings.Builder, re *syntax.Regexp, maxRepeat int) error {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText:
+		return errors.New("anchors are only supported at the start or end of the pattern")
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && g.rng.IntN(2) == 0 {
+				r = unicode.SimpleFold(r)
+			}
+			sb.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		sb.WriteRune(g.runeFromClass(re.Rune))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		sb.WriteRune(rune(' ' + g.rng.IntN('~'-' '+1)))
+	case syntax.OpCapture:
+		return g.writeRegex(sb, re.Sub[0], maxRepeat)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := g.writeRegex(sb, sub, maxRepeat); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		return g.writeRegex(sb, re.Sub[g.rng.IntN(len(re.Sub))], maxRepeat)
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			min, max = 0, -1
+		case syntax.OpPlus:
+			min, max = 1, -1
+		case syntax.OpQuest:
+			min, max = 0, 1
+		}
+		if max < 0 {
+			max = min + maxRepeat
+		}
+		for n := min + g.rng.IntN(max-min+1); n > 0; n-- {
+			if err := g.writeRegex(sb, re.Sub[0], maxRepeat); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported regex syntax %q", re.String())
+	}
+	return nil
+}
+
+// runeFromClass picks a rune from a character class given as inclusive
+// [lo, hi] pairs. Printable ASCII runes are preferred so negated classes
+// such as \D do not produce arbitrary Unicode.
+func (g *Generator) runeFromClass(ranges []rune) rune {
+	pick := func(lower, upper rune) (rune, bool) {
+		total := 0
+		for z := 0; z < len(ranges); z += 2 {
+			lo, hi := max(ranges[z], lower), min(ranges[z+1], upper)
+			if lo <= hi {
+				total += int(hi-lo) + 1
+			}
+		}
+		if total == 0 {
+			return 0, false
+		}
+		n := g.rng.IntN(total)
+		for z := 0; z < len(ranges); z += 2 {
+			lo, hi := max(ranges[z], lower), min(ranges[z+1], upper)
+			if lo > hi {
+				continue
+			}
+			if n < int(hi-lo)+1 {
+				return lo + rune(n), true
+			}
+			n -= int(hi-lo) + 1
+		}
+		return 0, false
+	}
+	if r, ok := pick(' ', '~'); ok {
+		return r
+	}
+	r, _ := pick(0, unicode.MaxRune)
+	return r
+}
@@ -0,0 +1,48 @@
+package generator
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestRegex(t *testing.T) {
+	patterns := []string{
+		`[a-f0-9]{8}`,
+		`^[A-Z]{3}-[0-9]{2,4}$`,
+		`^^(cat|dog)s?$$`,
+		`^`,
+		`^$`,
+		`(?i)abc`,
+		`x(y|z)*`,
+	}
+	for _, pattern := range patterns {
+		t.Run(pattern, func(t *testing.T) {
+			template := `{"r":{"$regex":` + strconv.Quote(pattern) + `}}`
+			re := regexp.MustCompile(`^(?:` + pattern + `)$`)
+			for _, v := range field(generateRecords(t, template, 20, 1), "r") {
+				if s, ok := v.(string); !ok || !re.MatchString(s) {
+					t.Errorf("got %#v, want a string matching %s", v, pattern)
+				}
+			}
+			assertDeterministic(t, template, 5)
+		})
+	}
+}
+
+func TestRegexErrors(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`"a^b"`, `pattern "a^b": anchors are only supported at the start or end of the pattern`},
+		{`"a$b"`, `pattern "a$b": anchors are only supported at the start or end of the pattern`},
+		{`"(^a|b)"`, `pattern "(^a|b)": anchors are only supported at the start or end of the pattern`},
+		{`"(?m)a^b"`, `pattern "(?m)a^b": anchors are only supported at the start or end of the pattern`},
+		{`"[a"`, `invalid pattern "[a"`},
+		{`{"pattern":"a","maxRepeat":-1}`, "invalid maxRepeat value for $regex"},
+	}
+	for _, tt := range tests {
+		assertGenerateError(t, `{"r":{"$regex":`+tt.params+`}}`, tt.want)
+	}
+}
@@ -0,0 +1,412 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// schemaAnnotations are JSON Schema keywords that do not constrain the
+// generated values, so they are skipped without a warning.
+var schemaAnnotations = map[string]bool{
+	"$schema":     true,
+	"$id":         true,
+	"$comment":    true,
+	"title":       true,
+	"description": true,
+	"default":     true,
+	"examples":    true,
+}
+
+// schemaKeywords are the JSON Schema keywords SchemaTemplate understands.
+var schemaKeywords = map[string]bool{
+	"type":       true,
+	"properties": true,
+	"required":   true,
+	"items":      true,
+	"enum":       true,
+	"const":      true,
+	"minimum":    true,
+	"maximum":    true,
+	"minLength":  true,
+	"maxLength":  true,
+	"minItems":   true,
+	"maxItems":   true,
+}
+
+// SchemaTemplate converts a JSON Schema (a subset of draft-07) into a
+// template whose generators are marked with prefix, so that the generated
+// records are instances of the schema. Optional properties are included
+// half of the time. Keywords it does not support are skipped, and each one
+// is reported in the returned warnings.
+func SchemaTemplate(schema interface{}, prefix string) (interface{}, []string, error) {
+	c := schemaConverter{prefix: prefix}
+	template, err := c.convert("#", schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	return template, c.warnings, nil
+}
+
+type schemaConverter struct {
+	prefix   string
+	warnings []string
+}
+
+func (c *schemaConverter) convert(path string, schema interface{}) (interface{}, error) {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		// true accepts any value and false none; neither narrows what to
+		// generate, so both produce a string
+		if _, isBool := schema.(bool); isBool {
+			return c.stringTemplate(nil), nil
+		}
+		return nil, fmt.Errorf("%s: schema must be an object, got %T", path, schema)
+	}
+	for _, keyword := range sortedKeys(s) {
+		if !schemaKeywords[keyword] && !schemaAnnotations[keyword] {
+			c.warnings = append(c.warnings, fmt.Sprintf("%s: unsupported keyword %q, skipped", path, keyword))
+		}
+	}
+
+	if value, exists := s["const"]; exists {
+		return c.generator("const", value), nil
+	}
+	if v, exists := s["enum"]; exists {
+		values, ok := v.([]interface{})
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("%s: enum must be a non-empty list", path)
+		}
+		choices := make([]interface{}, len(values))
+		for z, value := range values {
+			choices[z] = c.generator("const", value)
+		}
+		return c.generator("oneof", choices), nil
+	}
+
+	switch t := s["type"].(type) {
+	case string:
+		return c.typed(path, t, s)
+	case []interface{}:
+		if len(t) == 0 {
+			return nil, fmt.Errorf("%s: type must not be an empty list", path)
+		}
+		choices := make([]interface{}, len(t))
+		for z, elem := range t {
+			name, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: type must be a string or a list of strings", path)
+			}
+			var err error
+			if choices[z], err = c.typed(path, name, s); err != nil {
+				return nil, err
+			}
+		}
+		return c.generator("oneof", choices), nil
+	case nil:
+		// Infer the type from the keywords that only apply to one
+		switch {
+		case s["properties"] != nil:
+			return c.typed(path, "object", s)
+		case s["items"] != nil:
+			return c.typed(path, "array", s)
+		default:
+			return c.stringTemplate(s), nil
+		}
+	default:
+		return nil, fmt.Errorf("%s: type must be a string or a list of strings", path)
+	}
+}
+
+// typed returns the template for instances of the named type.
+func (c *schemaConverter) typed(path, name string, s map[string]interface{}) (interface{}, error) {
+	switch name {
+	case "integer":
+		return c.generator("int", c.bounds(s, "minimum", "maximum", 0, 1000)), nil
+	case "number":
+		return c.generator("float", c.bounds(s, "minimum", "maximum", 0, 1000)), nil
+	case "string":
+		return c.stringTemplate(s), nil
+	case "boolean":
+		return c.prefix + "bool", nil
+	case "null":
+		return c.prefix + "null", nil
+	case "array":
+		var val interface{} = c.stringTemplate(nil)
+		switch items := s["items"].(type) {
+		case nil:
+		case []interface{}:
+			c.warnings = append(c.warnings, fmt.Sprintf("%s: tuple items are not supported, skipped", path))
+		default:
+			var err error
+			if val, err = c.convert(path+"/items", items); err != nil {
+				return nil, err
+			}
+		}
+		return c.generator("arr", map[string]interface{}{
+			"len": c.bounds(s, "minItems", "maxItems", 0, 5),
+			"val": val,
+		}), nil
+	case "object":
+		return c.object(path, s)
+	default:
+		return nil, fmt.Errorf("%s: unknown type %q", path, name)
+	}
+}
+
+// object returns the template for an object with the schema's properties.
+func (c *schemaConverter) object(path string, s map[string]interface{}) (interface{}, error) {
+	required := make(map[string]bool)
+	if v, exists := s["required"]; exists {
+		names, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: required must be a list of strings", path)
+		}
+		for _, name := range names {
+			key, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: required must be a list of strings", path)
+			}
+			required[key] = true
+		}
+	}
+
+	template := make(map[string]interface{})
+	if v, exists := s["properties"]; exists {
+		properties, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: properties must be an object", path)
+		}
+		for _, key := range sortedKeys(properties) {
+			value, err := c.convert(path+"/properties/"+key, properties[key])
+			if err != nil {
+				return nil, err
+			}
+			if !required[key] {
+				value = c.generator("option", map[string]interface{}{"value": value})
+			}
+			template[c.literalKey(key)] = value
+		}
+	}
+	// Required properties without a definition can hold any value
+	names := make([]string, 0, len(required))
+	for key := range required {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		if _, exists := template[c.literalKey(key)]; !exists {
+			template[c.literalKey(key)] = c.stringTemplate(nil)
+		}
+	}
+	return template, nil
+}
+
+// stringTemplate returns the template for a string of lowercase letters
+// within the schema's length bounds.
+func (c *schemaConverter) stringTemplate(s map[string]interface{}) interface{} {
+	return c.generator("alpha", map[string]interface{}{
+		"case": "lower",
+		"len":  c.bounds(s, "minLength", "maxLength", 1, 10),
+	})
+}
+
+// bounds returns a {min, max} object from the schema's minKey and maxKey
+// values. A missing bound defaults to def, or to span away from the other
+// bound when only one is given.
+func (c *schemaConverter) bounds(s map[string]interface{}, minKey, maxKey string, def, span int) map[string]interface{} {
+	min, hasMin := s[minKey]
+	max, hasMax := s[maxKey]
+	switch {
+	case hasMin && !hasMax:
+		if f, ok := convertToFloat(min); ok {
+			max = json.Number(fmt.Sprint(int64(f) + int64(span)))
+		}
+	case hasMax && !hasMin:
+		min = json.Number(fmt.Sprint(def))
+		if f, ok := convertToFloat(max); ok && f < float64(def) {
+			// Lengths and item counts cannot go below zero
+			lo := int64(f) - int64(span)
+			if minKey != "minimum" && lo < 0 {
+				lo = 0
+			}
+			min = json.Number(fmt.Sprint(lo))
+		}
+	case !hasMin && !hasMax:
+		min, max = json.Number(fmt.Sprint(def)), json.Number(fmt.Sprint(def+span))
+	}
+	return map[string]interface{}{"min": min, "max": max}
+}
+
+func (c *schemaConverter) generator(name string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{c.prefix + name: params}
+}
+
+// literalKey escapes a property name that would otherwise be read as a
+// generator or variable.
+func (c *schemaConverter) literalKey(key string) string {
+	if strings.HasPrefix(key, c.prefix) {
+		return c.prefix + key
+	}
+	return key
+}
+
+// ValidateSchema checks that value is an instance of a JSON Schema, using
+// the same keywords as SchemaTemplate; other keywords are ignored. The error
+// names the first field and constraint that fails.
+func ValidateSchema(schema, value interface{}) error {
+	// Round-trip through JSON so that generated values have the types of
+	// decoded ones
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if value, err = ParseTemplate(data); err != nil {
+		return err
+	}
+	return validateInstance("$", schema, value)
+}
+
+func validateInstance(path string, schema, value interface{}) error {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		if accept, isBool := schema.(bool); isBool {
+			if !accept {
+				return fmt.Errorf("%s: no value is allowed", path)
+			}
+			return nil
+		}
+		return fmt.Errorf("%s: schema must be an object, got %T", path, schema)
+	}
+
+	if expected, exists := s["const"]; exists && !sameJSON(expected, value) {
+		return fmt.Errorf("%s: %s does not equal const %s", path, schemaJSON(value), schemaJSON(expected))
+	}
+	if v, exists := s["enum"]; exists {
+		values, _ := v.([]interface{})
+		found := false
+		for _, allowed := range values {
+			if sameJSON(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %s is not one of enum %s", path, schemaJSON(value), schemaJSON(v))
+		}
+	}
+	if v, exists := s["type"]; exists {
+		var types []interface{}
+		if list, ok := v.([]interface{}); ok {
+			types = list
+		} else {
+			types = []interface{}{v}
+		}
+		matched := false
+		for _, t := range types {
+			if name, ok := t.(string); ok && hasSchemaType(name, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: %s does not match type %s", path, schemaJSON(value), schemaJSON(v))
+		}
+	}
+
+	switch val := value.(type) {
+	case json.Number:
+		f, _ := convertToFloat(val)
+		if min, ok := convertToFloat(s["minimum"]); ok && f < min {
+			return fmt.Errorf("%s: %s is less than minimum %v", path, val, s["minimum"])
+		}
+		if max, ok := convertToFloat(s["maximum"]); ok && f > max {
+			return fmt.Errorf("%s: %s is greater than maximum %v", path, val, s["maximum"])
+		}
+	case string:
+		n := utf8.RuneCountInString(val)
+		if min, err := convertToInt(s["minLength"]); err == nil && n < min {
+			return fmt.Errorf("%s: length %d is less than minLength %d", path, n, min)
+		}
+		if max, err := convertToInt(s["maxLength"]); err == nil && n > max {
+			return fmt.Errorf("%s: length %d is greater than maxLength %d", path, n, max)
+		}
+	case []interface{}:
+		if min, err := convertToInt(s["minItems"]); err == nil && len(val) < min {
+			return fmt.Errorf("%s: %d items is less than minItems %d", path, len(val), min)
+		}
+		if max, err := convertToInt(s["maxItems"]); err == nil && len(val) > max {
+			return fmt.Errorf("%s: %d items is greater than maxItems %d", path, len(val), max)
+		}
+		if items, exists := s["items"]; exists {
+			if _, isTuple := items.([]interface{}); !isTuple {
+				for z, elem := range val {
+					if err := validateInstance(fmt.Sprintf("%s[%d]", path, z), items, elem); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case map[string]interface{}:
+		required, _ := s["required"].([]interface{})
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, exists := val[key]; !exists {
+					return fmt.Errorf("%s: missing required property %q", path, key)
+				}
+			}
+		}
+		properties, _ := s["properties"].(map[string]interface{})
+		for _, key := range sortedKeys(properties) {
+			if elem, exists := val[key]; exists {
+				if err := validateInstance(path+"."+key, properties[key], elem); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasSchemaType reports whether a decoded JSON value has the named JSON
+// Schema type.
+func hasSchemaType(name string, value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return name == "null"
+	case bool:
+		return name == "boolean"
+	case string:
+		return name == "string"
+	case json.Number:
+		if name == "number" {
+			return true
+		}
+		f, _ := convertToFloat(v)
+		return name == "integer" && f == math.Trunc(f)
+	case []interface{}:
+		return name == "array"
+	case map[string]interface{}:
+		return name == "object"
+	default:
+		return false
+	}
+}
+
+// sameJSON reports whether a and b encode to the same JSON.
+func sameJSON(a, b interface{}) bool {
+	ka, errA := jsonKey(a)
+	kb, errB := jsonKey(b)
+	return errA == nil && errB == nil && ka == kb
+}
+
+func schemaJSON(v interface{}) string {
+	s, err := jsonKey(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return s
+}
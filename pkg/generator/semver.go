@@ -0,0 +1,47 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+)
+
+var prereleaseTags = []string{"alpha", "beta", "rc"}
+
+// semver returns a random semantic version such as "1.4.2", optionally with
+// a prerelease ("-rc.2") and build metadata ("+build.45").
+func (g *Generator) semver(params interface{}) (string, error) {
+	maxes := map[string]int{"maxMajor": 10, "maxMinor": 20, "maxPatch": 50}
+	var prerelease, build bool
+	if paramsMap, ok := params.(map[string]interface{}); ok {
+		for _, name := range sortedKeys(maxes) {
+			if v, exists := paramsMap[name]; exists {
+				n, err := convertToInt(v)
+				if err != nil || n < 0 {
+					return "", fmt.Errorf("%s for $semver must be a non-negative integer", name)
+				}
+				maxes[name] = n
+			}
+		}
+		if v, exists := paramsMap["prerelease"]; exists {
+			if prerelease, ok = v.(bool); !ok {
+				return "", errors.New("prerelease for $semver must be a boolean")
+			}
+		}
+		if v, exists := paramsMap["build"]; exists {
+			if build, ok = v.(bool); !ok {
+				return "", errors.New("build for $semver must be a boolean")
+			}
+		}
+	} else if params != nil {
+		return "", errors.New("$semver accepts an optional {prerelease, build, maxMajor, maxMinor, maxPatch} object")
+	}
+
+	version := fmt.Sprintf("%d.%d.%d", g.rng.IntN(maxes["maxMajor"]+1), g.rng.IntN(maxes["maxMinor"]+1), g.rng.IntN(maxes["maxPatch"]+1))
+	if prerelease {
+		version += fmt.Sprintf("-%s.%d", prereleaseTags[g.rng.IntN(len(prereleaseTags))], 1+g.rng.IntN(9))
+	}
+	if build {
+		version += fmt.Sprintf("+build.%d", 1+g.rng.IntN(999))
+	}
+	return version, nil
+}
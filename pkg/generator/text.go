@@ -0,0 +1,148 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// caseTransforms maps the case transform generators to their functions.
+var caseTransforms = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"title": titleCase,
+}
+
+// transformCase resolves template and returns the result as a string in the
+// case of the named transform. Numbers and booleans are formatted as
+// strings; objects, arrays, and null are rejected.
+func (g *Generator) transformCase(name string, template interface{}, i int) (interface{}, error) {
+	s, err := g.stringify(name, template, i)
+	if err != nil {
+		return nil, err
+	}
+	return caseTransforms[name](s), nil
+}
+
+// stringify resolves template to a string for the named generator.
+func (g *Generator) stringify(name string, template interface{}, i int) (string, error) {
+	resolved, err := g.Generate(i, template)
+	if err != nil {
+		return "", err
+	}
+	switch v := resolved.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}, *OrderedMap, []interface{}, nil:
+		return "", fmt.Errorf("$%s requires a string value, got %T", name, resolved)
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+// titleCase upper-cases the first letter of each word and lower-cases the
+// rest.
+func titleCase(s string) string {
+	var sb strings.Builder
+	start := true
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			start = true
+			sb.WriteRune(r)
+			continue
+		}
+		if start {
+			sb.WriteRune(unicode.ToUpper(r))
+		} else {
+			sb.WriteRune(unicode.ToLower(r))
+		}
+		start = false
+	}
+	return sb.String()
+}
+
+// formatString formats args with format as fmt.Sprintf does. JSON numbers
+// are adapted to the verb they are formatted with, so that a whole number
+// read from the template works with %d and a generated integer works with
+// %f.
+func formatString(format string, args []interface{}) (string, error) {
+	verbs, err := formatVerbs(format)
+	if err != nil {
+		return "", err
+	}
+	if len(verbs) != len(args) {
+		return "", fmt.Errorf("format %q has %d verbs but %d args were given", format, len(verbs), len(args))
+	}
+	for z, verb := range verbs {
+		args[z] = formatArg(verb, args[z])
+	}
+	return fmt.Sprintf(format, args...), nil
+}
+
+// formatVerbs returns the verbs of a printf-style format in order.
+func formatVerbs(format string) ([]rune, error) {
+	var verbs []rune
+	runes := []rune(format)
+	for z := 0; z < len(runes); z++ {
+		if runes[z] != '%' {
+			continue
+		}
+		z++
+		for z < len(runes) && strings.ContainsRune("+-# 0123456789.", runes[z]) {
+			z++
+		}
+		if z == len(runes) {
+			return nil, fmt.Errorf("format %q ends with an incomplete verb", format)
+		}
+		switch runes[z] {
+		case '%':
+		case '*', '[':
+			return nil, fmt.Errorf("format %q: * widths and explicit argument indexes are not supported", format)
+		default:
+			verbs = append(verbs, runes[z])
+		}
+	}
+	return verbs, nil
+}
+
+// formatArg converts a number to the kind expected by verb.
+func formatArg(verb rune, arg interface{}) interface{} {
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'b', 'c':
+		if f, ok := arg.(float64); ok && f == math.Trunc(f) {
+			return int64(f)
+		}
+		if n, ok := arg.(json.Number); ok {
+			if v, err := n.Int64(); err == nil {
+				return v
+			}
+		}
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		if n, ok := arg.(json.Number); ok {
+			if v, err := n.Float64(); err == nil {
+				return v
+			}
+		}
+		v := reflect.ValueOf(arg)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(v.Uint())
+		}
+	}
+	return arg
+}
+
+// randomString returns n characters drawn uniformly from charset.
+func (g *Generator) randomString(charset string, n int) string {
+	runes := []rune(charset)
+	var sb strings.Builder
+	for z := 0; z < n; z++ {
+		sb.WriteRune(runes[g.rng.IntN(len(runes))])
+	}
+	return sb.String()
+}
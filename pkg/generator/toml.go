@@ -0,0 +1,190 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// tomlEncoder writes each record as a [[record]] entry of an array of
+// tables. Nested objects become sub-tables and lists of objects become
+// arrays of tables. TOML has no null, so null fields are left out.
+type tomlEncoder struct{}
+
+func (e tomlEncoder) Header() string {
+	return ""
+}
+
+func (e tomlEncoder) Encode(n int, v interface{}) (string, error) {
+	// Going through JSON gives the record the types of decoded values
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	decoded, err := ParseTemplate(data)
+	if err != nil {
+		return "", err
+	}
+	record, ok := decoded.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("toml output requires object records, got %T", v)
+	}
+
+	var sb strings.Builder
+	if n > 0 {
+		sb.WriteByte('\n')
+	}
+	if err := writeTOMLTable(&sb, "[[record]]", "record", record); err != nil {
+		return "", fmt.Errorf("toml record %d: %w", n, err)
+	}
+	return sb.String(), nil
+}
+
+func (e tomlEncoder) Footer(n int) string {
+	return ""
+}
+
+// writeTOMLTable writes the header of a table, its key/value pairs, and
+// then its sub-tables, whose headers extend path.
+func writeTOMLTable(sb *strings.Builder, header, path string, table map[string]interface{}) error {
+	sb.WriteString(header + "\n")
+	var tables, arrays []string
+	for _, key := range sortedKeys(table) {
+		switch v := table[key].(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			tables = append(tables, key)
+			continue
+		case []interface{}:
+			if isTOMLArrayOfTables(v) {
+				arrays = append(arrays, key)
+				continue
+			}
+		}
+		value, err := tomlValue(table[key])
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", path, key, err)
+		}
+		sb.WriteString(tomlKey(key) + " = " + value + "\n")
+	}
+	for _, key := range tables {
+		sub := path + "." + tomlKey(key)
+		if err := writeTOMLTable(sb, "\n["+sub+"]", sub, table[key].(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+	for _, key := range arrays {
+		sub := path + "." + tomlKey(key)
+		for _, elem := range table[key].([]interface{}) {
+			if err := writeTOMLTable(sb, "\n[["+sub+"]]", sub, elem.(map[string]interface{})); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTOMLArrayOfTables reports whether list is a non-empty list of objects.
+func isTOMLArrayOfTables(list []interface{}) bool {
+	for _, elem := range list {
+		if _, ok := elem.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return len(list) > 0
+}
+
+// tomlValue formats a scalar or a list of scalars. The elements of a list
+// must all be of the same kind.
+func tomlValue(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return tomlString(t), nil
+	case bool:
+		if t {
+			return "true", nil
+		}
+		return "false", nil
+	case json.Number:
+		// TOML integers are 64-bit, so larger ones are written as floats
+		if _, err := t.Int64(); err != nil && !strings.ContainsAny(t.String(), ".eE") {
+			return t.String() + ".0", nil
+		}
+		return t.String(), nil
+	case []interface{}:
+		elems := make([]string, len(t))
+		for z, elem := range t {
+			if tomlKind(elem) != tomlKind(t[0]) {
+				return "", errors.New("lists mixing different types are not supported")
+			}
+			var err error
+			if elems[z], err = tomlValue(elem); err != nil {
+				return "", err
+			}
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case nil:
+		return "", errors.New("null values in lists are not supported")
+	default:
+		return "", errors.New("objects in lists with other values are not supported")
+	}
+}
+
+func tomlKind(v interface{}) string {
+	switch v.(type) {
+	case json.Number:
+		return "number"
+	case []interface{}:
+		return "list"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// tomlKey returns key as a bare key if it only has letters, digits, '_'
+// and '-', and quoted otherwise.
+func tomlKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+	for _, r := range key {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return tomlString(key)
+		}
+	}
+	return key
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
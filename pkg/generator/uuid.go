@@ -0,0 +1,34 @@
+package generator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// uuid returns a random UUID of the given version (4 or 7) in canonical
+// lowercase hyphenated form. Version 7 UUIDs start with the current Unix
+// time in milliseconds so they sort by creation time.
+func (g *Generator) uuid(version int) (string, error) {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], g.rng.Uint64())
+	binary.BigEndian.PutUint64(b[8:], g.rng.Uint64())
+
+	switch version {
+	case 4:
+	case 7:
+		ms := uint64(time.Now().UnixMilli())
+		b[0] = byte(ms >> 40)
+		b[1] = byte(ms >> 32)
+		b[2] = byte(ms >> 24)
+		b[3] = byte(ms >> 16)
+		b[4] = byte(ms >> 8)
+		b[5] = byte(ms)
+	default:
+		return "", fmt.Errorf("unsupported UUID version %d (supported: 4, 7)", version)
+	}
+	b[6] = b[6]&0x0f | byte(version)<<4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
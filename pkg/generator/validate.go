@@ -0,0 +1,311 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// requiredParams lists the parameters each generator cannot do without.
+// Generators that also work without parameters are not listed.
+var requiredParams = map[string][]string{
+	"int":         {"min", "max"},
+	"float":       {"min", "max"},
+	"arr":         {"len", "val"},
+	"age":         {"birthdate"},
+	"zodiac":      {"birthdate"},
+	"measurement": {"quantity"},
+	"subset":      {"object"},
+	"line":        {"file"},
+	"register":    {"pool", "val"},
+	"edge":        {"from", "to"},
+	"padIndex":    {"width"},
+	"except":      {"value", "exclude"},
+	"freqStr":     {"length", "freq"},
+	"format":      {"fmt"},
+	"join":        {"sep", "values"},
+	"nullable":    {"value"},
+	"hex":         {"len"},
+	"bytes":       {"len"},
+	"alnum":       {"len"},
+	"repeat":      {"n", "val"},
+	"expr":        {"op", "args"},
+	"if":          {"cond", "then"},
+	"map":         {"source", "template"},
+	"pick":        {"from", "n"},
+	"seeded":      {"seed", "val"},
+}
+
+// knownParams lists every parameter of the generators that reject
+// parameters they do not take, so that a misspelled one is reported instead
+// of silently falling back to its default.
+var knownParams = map[string][]string{
+	"subset": {"object", "minKeys", "maxKeys"},
+}
+
+// checkParamNames returns an error for the first parameter in paramsMap
+// that the named generator does not take, if it is listed in knownParams.
+func checkParamNames(name string, paramsMap map[string]interface{}) error {
+	known, ok := knownParams[name]
+	if !ok {
+		return nil
+	}
+	for _, key := range sortedKeys(paramsMap) {
+		if !slices.Contains(known, key) {
+			return fmt.Errorf("$%s: unknown parameter %q (supported: %s)", name, key, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// literalParams lists the parameters each generator reads as plain strings,
+// such as separators and file paths, rather than resolving them as
+// templates.
+var literalParams = map[string][]string{
+	"join":        {"sep"},
+	"format":      {"fmt"},
+	"line":        {"file"},
+	"choice":      {"file"},
+	"register":    {"pool"},
+	"edge":        {"from", "to"},
+	"seq":         {"name"},
+	"expr":        {"op"},
+	"person":      {"country"},
+	"firstname":   {"country", "gender"},
+	"lastname":    {"country", "gender"},
+	"name":        {"country", "gender"},
+	"phone":       {"country"},
+	"ipv4":        {"cidr"},
+	"measurement": {"quantity", "unit"},
+	"alnum":       {"charset"},
+	"lorem":       {"unit"},
+	"date":        {"min", "max", "format"},
+	"datetime":    {"min", "max", "format"},
+	"timestamp":   {"min", "max", "unit"},
+	"regex":       {"pattern"},
+}
+
+// stringParams lists the generators that take a plain string as their
+// parameter.
+var stringParams = map[string]bool{
+	"ref":   true,
+	"regex": true,
+}
+
+// listParams lists the generators whose parameter must be a non-empty list.
+var listParams = map[string]bool{
+	"obj":   true,
+	"oneof": true,
+}
+
+// Validate walks template once without generating anything and reports every
+// unknown generator, missing or malformed parameter, and undefined variable.
+func (g *Generator) Validate(template interface{}) error {
+	var errs []error
+	g.validate("$", template, &errs)
+	return errors.Join(errs...)
+}
+
+// validate checks template at path.
+func (g *Generator) validate(path string, template interface{}, errs *[]error) {
+	report := func(format string, args ...interface{}) {
+		*errs = append(*errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	switch t := template.(type) {
+	case map[string]interface{}:
+		keys := sortedKeys(t)
+		for _, key := range keys {
+			name, ok := g.generatorName(key)
+			if !ok {
+				continue
+			}
+			if len(t) > 1 {
+				report("generator %q cannot be combined with other keys in the same object", key)
+				return
+			}
+			g.validateParams(path+"."+key, name, t[key], errs)
+			return
+		}
+		for _, key := range keys {
+			if g.undefinedVar(key) {
+				report("unknown generator or variable %q", key)
+			}
+			g.validate(path+"."+key, t[key], errs)
+		}
+	case []interface{}:
+		for z, elem := range t {
+			g.validate(fmt.Sprintf("%s[%d]", path, z), elem, errs)
+		}
+	case string:
+		if g.undefinedVar(t) {
+			report("undefined variable: %q", t)
+		}
+	}
+}
+
+// undefinedVar reports whether s refers to a variable that is neither a
+// generator nor user-defined.
+func (g *Generator) undefinedVar(s string) bool {
+	if !strings.HasPrefix(s, g.prefix) {
+		return false
+	}
+	if _, ok := g.unescape(s); ok {
+		return false
+	}
+	name := strings.TrimPrefix(s, g.prefix)
+	if isPredefinedVar(name) || strings.HasPrefix(name, "ref:") {
+		return false
+	}
+	if _, exists := g.locals[name]; exists {
+		return false
+	}
+	_, exists := g.vars[name]
+	return !exists
+}
+
+// validateParams checks the shape of a generator's parameters, then walks
+// the ones that are templates.
+func (g *Generator) validateParams(path, name string, params interface{}, errs *[]error) {
+	// $const emits its parameter verbatim, so there is nothing to check
+	if name == "const" {
+		return
+	}
+	if required, ok := requiredParams[name]; ok {
+		paramsMap, ok := params.(map[string]interface{})
+		if !ok {
+			*errs = append(*errs, fmt.Errorf("%s: $%s requires a {%s} object", path, name, strings.Join(required, ", ")))
+			return
+		}
+		for _, param := range required {
+			if _, exists := paramsMap[param]; !exists {
+				*errs = append(*errs, fmt.Errorf("%s: missing %s for $%s", path, param, name))
+			}
+		}
+	}
+	if paramsMap, ok := params.(map[string]interface{}); ok {
+		if err := checkParamNames(name, paramsMap); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	// Read $choice files up front so a missing or empty file is reported
+	// before any record is generated
+	if name == "choice" {
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			if file, ok := paramsMap["file"].(string); ok {
+				if _, err := g.choiceLines(file); err != nil {
+					*errs = append(*errs, fmt.Errorf("%s: %w", path, err))
+				}
+			} else if _, exists := paramsMap["values"]; !exists {
+				*errs = append(*errs, fmt.Errorf("%s: $choice requires a file or values", path))
+			}
+		}
+	}
+	// The variable bound by $map is only defined within its template
+	if name == "map" {
+		if paramsMap, ok := params.(map[string]interface{}); ok {
+			local, err := g.mapVariable(paramsMap)
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("%s: %w", path, err))
+				return
+			}
+			g.validate(path+".source", paramsMap["source"], errs)
+			outer, shadowed := g.locals[local]
+			g.locals[local] = nil
+			g.validate(path+".template", paramsMap["template"], errs)
+			g.restoreLocal(local, outer, shadowed)
+			return
+		}
+	}
+	if listParams[name] {
+		if paramsMap, ok := params.(map[string]interface{}); ok && name == "oneof" && paramsMap["probabilities"] != nil {
+			g.validate(path, params, errs)
+			return
+		}
+		if list, ok := params.([]interface{}); !ok || len(list) == 0 {
+			*errs = append(*errs, fmt.Errorf("%s: $%s requires a non-empty list", path, name))
+			return
+		}
+	}
+	if _, ok := params.(string); ok && stringParams[name] {
+		return
+	}
+	if paramsMap, ok := params.(map[string]interface{}); ok && literalParams[name] != nil {
+		literal := make(map[string]bool, len(literalParams[name]))
+		for _, param := range literalParams[name] {
+			literal[param] = true
+		}
+		for _, key := range sortedKeys(paramsMap) {
+			if !literal[key] {
+				g.validate(path+"."+key, paramsMap[key], errs)
+			}
+		}
+		return
+	}
+	g.validate(path, params, errs)
+}
+
+// statefulGenerators lists the generators whose values depend on the records
+// generated before by the same Generator.
+var statefulGenerators = map[string]bool{
+	"seq":      true,
+	"incr":     true,
+	"unique":   true,
+	"register": true,
+	"edge":     true,
+}
+
+// StatefulGenerators returns the generators used by template, or by the
+// variables it refers to, that keep state across records, such as $seq and
+// $unique. Their values depend on every record the Generator has generated,
+// so records cannot be split between several Generators without changing
+// them.
+func (g *Generator) StatefulGenerators(template interface{}) []string {
+	found := make(map[string]bool)
+	g.findStateful(template, make(map[string]bool), found)
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, g.prefix+name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findStateful adds the stateful generators used by template to found,
+// expanding each user-defined variable once.
+func (g *Generator) findStateful(template interface{}, expanded, found map[string]bool) {
+	switch t := template.(type) {
+	case map[string]interface{}:
+		for key, v := range t {
+			if name, ok := g.generatorName(key); ok {
+				if statefulGenerators[name] {
+					found[name] = true
+				}
+				if name == "const" {
+					continue
+				}
+			} else {
+				g.findStateful(key, expanded, found)
+			}
+			g.findStateful(v, expanded, found)
+		}
+	case []interface{}:
+		for _, elem := range t {
+			g.findStateful(elem, expanded, found)
+		}
+	case string:
+		if _, ok := g.unescape(t); ok || !strings.HasPrefix(t, g.prefix) {
+			return
+		}
+		name := strings.TrimPrefix(t, g.prefix)
+		if statefulGenerators[name] {
+			found[name] = true
+		}
+		if v, exists := g.vars[name]; exists && !isPredefinedVar(name) && !expanded[name] {
+			expanded[name] = true
+			g.findStateful(v, expanded, found)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateSkipsLiteralParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string // substring of the error, empty if valid
+	}{
+		{"join sep", `{"a":{"$join":{"sep":"$","values":["$u8","$u8"]}}}`, ""},
+		{"format fmt", `{"a":{"$format":{"fmt":"$%d","args":["$u8"]}}}`, ""},
+		{"alnum charset", `{"a":{"$alnum":{"len":3,"charset":"$#"}}}`, ""},
+		{"register pool", `{"a":{"$register":{"pool":"$users","val":"$u8"}}}`, ""},
+		{"regex pattern", `{"a":{"$regex":"$[0-9]"}}`, ""},
+		{"templates still checked", `{"a":{"$join":{"sep":"$","values":["$nope"]}}}`, `$.a.$join.values[0]: undefined variable: "$nope"`},
+		{"format args still checked", `{"a":{"$format":{"fmt":"%d","args":["$nope"]}}}`, `undefined variable: "$nope"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			template, err := g.Parse([]byte(tt.template))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = g.Validate(template)
+			if tt.want == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatefulGenerators(t *testing.T) {
+	vars := map[string]string{
+		"id":    `{"$seq":"ids"}`,
+		"outer": `{"inner":"$id"}`,
+		"loop":  `{"self":"$loop"}`,
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{"stateless", `{"a":"$u8","b":{"$int":{"min":1,"max":2}}}`, []string{}},
+		{"generator object", `{"a":{"$unique":"$u8"}}`, []string{"$unique"}},
+		{"in parameters", `{"a":{"$arr":{"len":2,"val":{"$incr":null}}}}`, []string{"$incr"}},
+		{"in a list", `{"a":[{"$register":{"pool":"p","val":1}},{"$edge":{"from":"p","to":"p"}}]}`, []string{"$edge", "$register"}},
+		{"through variables", `{"a":"$outer"}`, []string{"$seq"}},
+		{"recursive variable", `{"a":"$loop"}`, []string{}},
+		{"const", `{"a":{"$const":{"$seq":"x"}}}`, []string{}},
+		{"escaped", `{"a":"$$seq"}`, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			template, err := g.Parse([]byte(tt.template))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := g.StatefulGenerators(template); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}